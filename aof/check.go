@@ -0,0 +1,144 @@
+package aof
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// Report is the result of Check.
+type Report struct {
+	// Size of the file.
+	Size int64
+
+	// Length of the valid part of the file, Size if the file is valid.
+	ValidLength int64
+
+	// Whether the file starts with an RDB preamble.
+	RdbPreamble bool
+
+	// Number of commands in the valid part of the file.
+	Commands int
+
+	// Problem found in the file, a *TruncatedError, *FormatError or
+	// *PreambleError. Nil if the file is valid.
+	Problem error
+}
+
+func (r Report) Valid() bool {
+	return r.Problem == nil
+}
+
+// Check validates every command of the AOF read from r, as redis-check-aof
+// does. Besides truncated and malformed commands it reports nested MULTI,
+// EXEC without MULTI and a MULTI/EXEC block left open at the end of the file.
+// The returned error is only for failures to read r, problems of the AOF
+// are in Report.Problem.
+//
+// redis-check-aof.c::process
+func Check(r io.ReaderAt) (Report, error) {
+	var report Report
+	cr := &countingReader{r: io.NewSectionReader(r, 0, math.MaxInt64)}
+	p, err := NewParser(cr)
+	if err != nil {
+		return report, err
+	}
+	s, err := p.Parse()
+	if err != nil {
+		return report, err
+	}
+
+	var (
+		commands    int
+		inMulti     bool
+		multiOffset int64
+	)
+loop:
+	for s.HasNext() {
+		switch e := s.Next().Event.(type) {
+		case *RdbEvent:
+			report.RdbPreamble = true
+		case *CommandEvent:
+			commands++
+			switch e.Name() {
+			case "MULTI":
+				if inMulti {
+					report.Problem = &FormatError{Offset: e.Offset, ValidLength: multiOffset, Reason: "unexpected MULTI"}
+					break loop
+				}
+				inMulti = true
+				multiOffset = e.Offset
+			case "EXEC":
+				if !inMulti {
+					report.Problem = &FormatError{Offset: e.Offset, ValidLength: e.Offset, Reason: "unexpected EXEC"}
+					break loop
+				}
+				inMulti = false
+			}
+			if !inMulti {
+				report.ValidLength = e.Offset + e.Size
+				report.Commands = commands
+			}
+		}
+	}
+
+	if report.Problem == nil {
+		var (
+			truncErr    *TruncatedError
+			formatErr   *FormatError
+			preambleErr *PreambleError
+		)
+		switch err := s.Err(); {
+		case err == nil:
+		case errors.As(err, &truncErr):
+			report.Problem = err
+			report.ValidLength = truncErr.ValidLength
+		case errors.As(err, &formatErr):
+			report.Problem = err
+			report.ValidLength = formatErr.ValidLength
+		case errors.As(err, &preambleErr):
+			report.Problem = err
+			report.ValidLength = 0
+		default:
+			return report, err
+		}
+	}
+
+	// Read the rest of the file to know its size.
+	if _, err := io.Copy(io.Discard, cr); err != nil {
+		return report, err
+	}
+	report.Size = cr.n
+	if report.Problem == nil {
+		report.ValidLength = report.Size
+	}
+	return report, nil
+}
+
+// Fix checks the AOF file name and truncates it to its valid part, as
+// redis-check-aof --fix does. Everything after the first problem is lost,
+// the returned report tells how much. A file with a corrupt RDB preamble
+// can not be fixed.
+func Fix(name string) (Report, error) {
+	f, err := os.OpenFile(name, os.O_RDWR, 0)
+	if err != nil {
+		return Report{}, err
+	}
+	defer f.Close()
+
+	report, err := Check(f)
+	if err != nil || report.Valid() {
+		return report, err
+	}
+	var preambleErr *PreambleError
+	if errors.As(report.Problem, &preambleErr) {
+		return report, fmt.Errorf("can not fix %s: %w", name, report.Problem)
+	}
+
+	if err := f.Truncate(report.ValidLength); err != nil {
+		return report, err
+	}
+	return report, f.Sync()
+}
@@ -0,0 +1,129 @@
+package aof
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	set := command("SET", "a", "1")
+	multi := command("MULTI") + command("INCR", "b") + command("EXEC")
+
+	tests := []struct {
+		name        string
+		in          string
+		commands    int
+		validLength int
+		problem     string
+	}{
+		{
+			name:        "valid",
+			in:          set + "#TS:1700000000\r\n" + multi,
+			commands:    4,
+			validLength: len(set + "#TS:1700000000\r\n" + multi),
+		},
+		{
+			name:        "rdb preamble",
+			in:          rdbFile + set,
+			commands:    1,
+			validLength: len(rdbFile + set),
+		},
+		{
+			name:        "truncated",
+			in:          set + set[:9],
+			commands:    1,
+			validLength: len(set),
+			problem:     "unexpected end of file",
+		},
+		{
+			name:        "open multi",
+			in:          set + command("MULTI") + command("INCR", "b"),
+			commands:    1,
+			validLength: len(set),
+			problem:     "unexpected end of file",
+		},
+		{
+			name:        "nested multi",
+			in:          set + command("MULTI") + command("MULTI") + command("EXEC") + set,
+			commands:    1,
+			validLength: len(set),
+			problem:     "unexpected MULTI",
+		},
+		{
+			name:        "exec without multi",
+			in:          set + command("EXEC") + set,
+			commands:    1,
+			validLength: len(set),
+			problem:     "unexpected EXEC",
+		},
+		{
+			name:        "bad prefix",
+			in:          set + "$3\r\nfoo\r\n" + set,
+			commands:    1,
+			validLength: len(set),
+			problem:     "expected '*', got '$'",
+		},
+		{
+			name:        "bulk not terminated",
+			in:          set + multi[:len(multi)-2] + "xx" + set,
+			commands:    1,
+			validLength: len(set),
+			problem:     "bulk payload not terminated by CRLF",
+		},
+		{
+			name:     "corrupt preamble",
+			in:       rdbFile[:12] + set,
+			commands: 0,
+			problem:  "RDB preamble",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := Check(strings.NewReader(tt.in))
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, int64(len(tt.in)), report.Size)
+			assert.Equal(t, int64(tt.validLength), report.ValidLength)
+			assert.Equal(t, tt.commands, report.Commands)
+			if tt.problem == "" {
+				assert.True(t, report.Valid())
+			} else {
+				assert.ErrorContains(t, report.Problem, tt.problem)
+			}
+		})
+	}
+}
+
+func TestFix(t *testing.T) {
+	set := command("SET", "a", "1")
+	name := filepath.Join(t.TempDir(), "appendonly.aof")
+	if err := os.WriteFile(name, []byte(set+set[:9]), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := Fix(name)
+	assert.NoError(t, err)
+	var truncErr *TruncatedError
+	assert.True(t, errors.As(report.Problem, &truncErr))
+
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, set, string(data))
+
+	report, err = Fix(name)
+	assert.NoError(t, err)
+	assert.True(t, report.Valid())
+
+	if err := os.WriteFile(name, []byte(rdbFile[:12]), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err = Fix(name)
+	assert.Error(t, err)
+}
@@ -0,0 +1,87 @@
+package aof
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/vczyh/redis-lib/rdb"
+)
+
+type EventType uint8
+
+const (
+	EventTypeCommand EventType = iota
+	EventTypeRdb
+	EventTypeTimestamp
+)
+
+type RedisAofEvent struct {
+	EventType EventType
+	Event     Event
+}
+
+type Event interface {
+	Debug()
+}
+
+// CommandEvent is a command written to the AOF. SELECT is not reported as a
+// command of its own, Db is the database the command applies to instead.
+type CommandEvent struct {
+	// File of a multi part AOF the command is read from, empty for
+	// NewParser.
+	File string
+
+	Db   int
+	Args [][]byte
+
+	// Offset of the command in the file and the number of bytes it takes.
+	Offset int64
+	Size   int64
+}
+
+// Name returns the command name in upper case.
+func (e *CommandEvent) Name() string {
+	if len(e.Args) == 0 {
+		return ""
+	}
+	return strings.ToUpper(string(e.Args[0]))
+}
+
+func (e *CommandEvent) Debug() {
+	fmt.Printf("=== CommandEvent ===\n")
+	fmt.Printf("Db: %d\n", e.Db)
+	fmt.Printf("Offset: %d\n", e.Offset)
+	for i, arg := range e.Args {
+		if i > 0 {
+			fmt.Printf(" ")
+		}
+		fmt.Printf("%q", arg)
+	}
+	fmt.Printf("\n\n")
+}
+
+// TimestampEvent is a "#TS:" annotation, written when aof-timestamp-enabled
+// is yes. The commands following it were executed at Time or later, with
+// second precision.
+type TimestampEvent struct {
+	File   string
+	Time   time.Time
+	Offset int64
+}
+
+func (e *TimestampEvent) Debug() {
+	fmt.Printf("=== TimestampEvent ===\n")
+	fmt.Printf("%s\n", e.Time.Format(time.RFC3339))
+	fmt.Printf("\n")
+}
+
+// RdbEvent is an event of an RDB formatted base file.
+type RdbEvent struct {
+	File  string
+	Event *rdb.RedisRdbEvent
+}
+
+func (e *RdbEvent) Debug() {
+	e.Event.Event.Debug()
+}
@@ -0,0 +1,174 @@
+package aof
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/vczyh/redis-lib/resp"
+)
+
+type FileType byte
+
+const (
+	FileTypeBase    FileType = 'b'
+	FileTypeHistory FileType = 'h'
+	FileTypeIncr    FileType = 'i'
+)
+
+// Prefix of the files Redis writes before renaming them, e.g. the manifest
+// left behind by an interrupted rewrite.
+//
+// aof.c::TEMP_FILE_NAME_PREFIX
+const tempFilePrefix = "temp-"
+
+// ManifestFile is a file of a multi part AOF, as listed in the manifest.
+type ManifestFile struct {
+	Name string
+	Seq  int64
+	Type FileType
+}
+
+// Manifest lists the files of a Redis 7 multi part AOF stored in the
+// appenddirname directory.
+type Manifest struct {
+	// Base file, RDB or AOF formatted. It is nil if the AOF was never
+	// rewritten.
+	Base *ManifestFile
+
+	// Incremental files, in sequence order.
+	Incrs []ManifestFile
+
+	// Files of earlier rewrites waiting to be deleted.
+	History []ManifestFile
+}
+
+// ReadManifest reads a manifest such as
+//
+//	file appendonly.aof.1.base.rdb seq 1 type b
+//	file appendonly.aof.1.incr.aof seq 1 type i
+//
+// aof.c::aofLoadManifestFromFile
+func ReadManifest(r io.Reader) (*Manifest, error) {
+	m := &Manifest{}
+	br := bufio.NewReader(r)
+	for lineNum := 1; ; lineNum++ {
+		line, err := br.ReadBytes('\n')
+		if err == io.EOF {
+			if len(line) == 0 {
+				break
+			}
+			return nil, fmt.Errorf("invalid AOF manifest: line %d is not terminated", lineNum)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if line[0] == '#' {
+			continue
+		}
+
+		args, err := resp.SplitArgs(line)
+		if err != nil || len(args) < 6 || len(args)%2 != 0 {
+			return nil, fmt.Errorf("invalid AOF manifest: line %d", lineNum)
+		}
+
+		var (
+			f       ManifestFile
+			hasSeq  bool
+			hasType bool
+		)
+		for i := 0; i < len(args); i += 2 {
+			value := string(args[i+1])
+			switch string(args[i]) {
+			case "file":
+				f.Name = value
+			case "seq":
+				seq, err := strconv.ParseInt(value, 10, 64)
+				if err != nil {
+					return nil, fmt.Errorf("invalid AOF manifest: line %d: bad seq %q", lineNum, value)
+				}
+				f.Seq = seq
+				hasSeq = true
+			case "type":
+				if len(value) != 1 {
+					return nil, fmt.Errorf("invalid AOF manifest: line %d: bad type %q", lineNum, value)
+				}
+				f.Type = FileType(value[0])
+				hasType = true
+			}
+		}
+		if f.Name == "" || !hasSeq || !hasType {
+			return nil, fmt.Errorf("invalid AOF manifest: line %d: missing file, seq or type", lineNum)
+		}
+
+		switch f.Type {
+		case FileTypeBase:
+			if m.Base != nil {
+				return nil, fmt.Errorf("invalid AOF manifest: duplicate base file %s", f.Name)
+			}
+			m.Base = &f
+		case FileTypeIncr:
+			m.Incrs = append(m.Incrs, f)
+		case FileTypeHistory:
+			m.History = append(m.History, f)
+		default:
+			return nil, fmt.Errorf("invalid AOF manifest: line %d: unknown type %q", lineNum, f.Type)
+		}
+	}
+
+	sort.SliceStable(m.Incrs, func(i, j int) bool {
+		return m.Incrs[i].Seq < m.Incrs[j].Seq
+	})
+	return m, nil
+}
+
+// OpenDir creates a parser for the multi part AOF in dir, the appenddirname
+// directory of Redis 7. The base file is parsed first, with rdb.Parser if it
+// is RDB formatted, followed by the incremental files in sequence order.
+// Offsets of the events are relative to the file named by their File field.
+func OpenDir(dir string, opts ...ParserOption) (*Parser, error) {
+	all, err := filepath.Glob(filepath.Join(dir, "*.manifest"))
+	if err != nil {
+		return nil, err
+	}
+	var matches []string
+	for _, name := range all {
+		if !strings.HasPrefix(filepath.Base(name), tempFilePrefix) {
+			matches = append(matches, name)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("no AOF manifest found in %s", dir)
+	case 1:
+	default:
+		return nil, fmt.Errorf("more than one AOF manifest found in %s", dir)
+	}
+
+	f, err := os.Open(matches[0])
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	m, err := ReadManifest(f)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Parser{}
+	for _, opt := range opts {
+		opt(p)
+	}
+	if m.Base != nil {
+		p.files = append(p.files, filepath.Join(dir, m.Base.Name))
+	}
+	for _, incr := range m.Incrs {
+		p.files = append(p.files, filepath.Join(dir, incr.Name))
+	}
+	return p, nil
+}
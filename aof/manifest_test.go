@@ -0,0 +1,127 @@
+package aof
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/vczyh/redis-lib/rdb"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// rdbFile is a minimal RDB holding the string key "a" in database 0.
+const rdbFile = "REDIS0009" + "\xfe\x00" + "\x00\x01a\x011" + "\xff" + "\x00\x00\x00\x00\x00\x00\x00\x00"
+
+func writeDir(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestReadManifest(t *testing.T) {
+	m, err := ReadManifest(strings.NewReader("" +
+		"file appendonly.aof.2.base.rdb seq 2 type b\n" +
+		"file appendonly.aof.1.base.rdb seq 1 type h\n" +
+		"file appendonly.aof.4.incr.aof seq 4 type i\n" +
+		"file \"appendonly 3.aof\" seq 3 type i\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, &ManifestFile{Name: "appendonly.aof.2.base.rdb", Seq: 2, Type: FileTypeBase}, m.Base)
+	assert.Equal(t, []ManifestFile{
+		{Name: "appendonly 3.aof", Seq: 3, Type: FileTypeIncr},
+		{Name: "appendonly.aof.4.incr.aof", Seq: 4, Type: FileTypeIncr},
+	}, m.Incrs)
+	assert.Equal(t, []ManifestFile{{Name: "appendonly.aof.1.base.rdb", Seq: 1, Type: FileTypeHistory}}, m.History)
+
+	for _, in := range []string{
+		"file a seq 1 type b",
+		"file a seq 1\n",
+		"file a seq x type i\n",
+		"file a seq 1 type x\n",
+		"file a seq 1 type b\nfile b seq 2 type b\n",
+	} {
+		_, err := ReadManifest(strings.NewReader(in))
+		assert.Error(t, err, in)
+	}
+}
+
+func TestOpenDir(t *testing.T) {
+	dir := writeDir(t, map[string]string{
+		"appendonly.aof.manifest": "file appendonly.aof.1.base.rdb seq 1 type b\n" +
+			"file appendonly.aof.3.incr.aof seq 3 type i\n" +
+			"file appendonly.aof.2.incr.aof seq 2 type i\n",
+		"appendonly.aof.1.base.rdb": rdbFile,
+		"appendonly.aof.2.incr.aof": command("SELECT", "1") + command("SET", "b", "2"),
+		// Every file starts with database 0.
+		"appendonly.aof.3.incr.aof": command("DEL", "a"),
+		// Left behind by an interrupted rewrite.
+		"temp-appendonly.aof.manifest": "file appendonly.aof.4.base.rdb seq 4 type b\n",
+	})
+	p, err := OpenDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := p.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for s.HasNext() {
+		switch e := s.Next().Event.(type) {
+		case *RdbEvent:
+			if o, ok := e.Event.Event.(*rdb.StringObjectEvent); ok {
+				got = append(got, "rdb "+o.Key+" "+o.Value)
+			}
+		case *CommandEvent:
+			got = append(got, filepath.Base(e.File)+" "+strconv.Itoa(e.Db)+": "+string(e.Args[0])+" "+string(e.Args[1]))
+		}
+	}
+	assert.NoError(t, s.Err())
+	assert.Equal(t, []string{
+		"rdb a 1",
+		"appendonly.aof.2.incr.aof 1: SET b",
+		"appendonly.aof.3.incr.aof 0: DEL a",
+	}, got)
+}
+
+func TestOpenDir_Truncated(t *testing.T) {
+	files := map[string]string{
+		"appendonly.aof.manifest": "file appendonly.aof.1.base.aof seq 1 type b\n" +
+			"file appendonly.aof.1.incr.aof seq 1 type i\n",
+		"appendonly.aof.1.base.aof": command("SET", "a", "1"),
+		"appendonly.aof.1.incr.aof": command("SET", "b", "2") + "*2\r\n$3",
+	}
+	p, err := OpenDir(writeDir(t, files), WithIgnoreTruncated())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, _ := p.Parse()
+	n := 0
+	for s.HasNext() {
+		n++
+	}
+	assert.NoError(t, s.Err())
+	assert.Equal(t, 2, n)
+
+	// Only the last file may be truncated.
+	files["appendonly.aof.1.base.aof"] = "*2\r\n$3"
+	p, err = OpenDir(writeDir(t, files), WithIgnoreTruncated())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, _ = p.Parse()
+	for s.HasNext() {
+	}
+	var truncErr *TruncatedError
+	if assert.True(t, errors.As(s.Err(), &truncErr)) {
+		assert.Equal(t, "appendonly.aof.1.base.aof", filepath.Base(truncErr.File))
+		assert.Equal(t, int64(0), truncErr.ValidLength)
+	}
+}
@@ -0,0 +1,436 @@
+package aof
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/vczyh/redis-lib/rdb"
+	"github.com/vczyh/redis-lib/resp"
+)
+
+const (
+	rdbMagic        = "REDIS"
+	timestampPrefix = "#TS:"
+
+	// Enough for "*<count>\r\n" with any 64 bit count.
+	multibulkLenPeekSize = 32
+)
+
+// TruncatedError is returned when the AOF ends in the middle of a command or
+// of a MULTI/EXEC block, as happens when Redis is killed while writing it.
+type TruncatedError struct {
+	// File of a multi part AOF that is truncated, empty for NewParser.
+	File string
+
+	// Length of the valid part of the file. Truncating the file to this
+	// length makes it loadable, as redis-check-aof --fix does.
+	ValidLength int64
+}
+
+func (e *TruncatedError) Error() string {
+	if e.File != "" {
+		return fmt.Sprintf("%s: unexpected end of file, the AOF is valid up to offset %d", e.File, e.ValidLength)
+	}
+	return fmt.Sprintf("unexpected end of file, the AOF is valid up to offset %d", e.ValidLength)
+}
+
+// FormatError is returned for data that is not a valid AOF.
+type FormatError struct {
+	// File of a multi part AOF that is invalid, empty for NewParser.
+	File string
+
+	// Offset of the invalid record.
+	Offset int64
+
+	// Length of the valid part of the file, before the invalid record and
+	// the MULTI/EXEC block it belongs to.
+	ValidLength int64
+
+	Reason string
+}
+
+func (e *FormatError) Error() string {
+	msg := fmt.Sprintf("bad file format reading the append only file at offset %d: %s", e.Offset, e.Reason)
+	if e.File != "" {
+		return e.File + ": " + msg
+	}
+	return msg
+}
+
+// PreambleError is returned when the RDB preamble of an AOF can not be
+// parsed. Unlike the commands, Redis refuses to load a truncated preamble.
+type PreambleError struct {
+	File string
+	Err  error
+}
+
+func (e *PreambleError) Error() string {
+	if e.File != "" {
+		return e.File + ": RDB preamble: " + e.Err.Error()
+	}
+	return "RDB preamble: " + e.Err.Error()
+}
+
+func (e *PreambleError) Unwrap() error {
+	return e.Err
+}
+
+type Parser struct {
+	r  *bufio.Reader
+	cr *resp.CommandReader
+
+	// File being parsed and the files of a multi part AOF still to be
+	// parsed, see OpenDir.
+	file  string
+	fd    *os.File
+	files []string
+
+	// The RDB preamble of the file, see parsePreamble.
+	preambleChecked bool
+	rdbStreamer     *rdb.EventStreamer
+	rdbReader       *countingReader
+
+	// Offset of cr in the file. Annotation lines are read around cr.
+	base int64
+
+	db int
+
+	// Commands of a MULTI/EXEC block are held back until EXEC is read, so
+	// that a truncated transaction is not reported at all.
+	multi       []*RedisAofEvent
+	inMulti     bool
+	multiOffset int64
+
+	// Events ready to be returned.
+	pending []*RedisAofEvent
+
+	// Stop at a truncated tail instead of failing, see WithIgnoreTruncated.
+	ignoreTruncated bool
+
+	err error
+}
+
+type ParserOption func(p *Parser)
+
+// WithIgnoreTruncated makes the parser stop without an error when the AOF
+// ends with an incomplete command or transaction, like Redis does with
+// aof-load-truncated yes. Everything before it is still reported.
+func WithIgnoreTruncated() ParserOption {
+	return func(p *Parser) {
+		p.ignoreTruncated = true
+	}
+}
+
+func NewParser(r io.Reader, opts ...ParserOption) (*Parser, error) {
+	p := &Parser{}
+	for _, opt := range opts {
+		opt(p)
+	}
+	p.reset(r)
+	return p, nil
+}
+
+// reset starts parsing r, a whole AOF file.
+func (p *Parser) reset(r io.Reader) {
+	p.preambleChecked = false
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	p.r = br
+	p.cr = resp.NewCommandReader(br)
+	p.base = 0
+	// Every file starts with database 0.
+	p.db = 0
+}
+
+func (p *Parser) Parse() (*EventStreamer, error) {
+	return newEventStreamer(p), nil
+}
+
+func (p *Parser) offset() int64 {
+	return p.base + p.cr.Offset()
+}
+
+// next returns the next event, or io.EOF at the end of the file.
+//
+// aof.c::loadSingleAppendOnlyFile
+func (p *Parser) next() (*RedisAofEvent, error) {
+	for len(p.pending) == 0 {
+		if p.err != nil {
+			return nil, p.err
+		}
+
+		if p.rdbStreamer != nil {
+			if p.rdbStreamer.HasNext() {
+				p.pending = append(p.pending, &RedisAofEvent{
+					EventType: EventTypeRdb,
+					Event:     &RdbEvent{File: p.file, Event: p.rdbStreamer.Next()},
+				})
+				continue
+			}
+			if err := p.rdbStreamer.Err(); err != nil {
+				p.err = &PreambleError{File: p.file, Err: err}
+				continue
+			}
+			// Continue with the commands after the preamble.
+			p.base = p.rdbReader.n
+			p.rdbStreamer = nil
+			p.rdbReader = nil
+			continue
+		}
+
+		if p.r == nil {
+			p.err = p.openNext()
+			continue
+		}
+		if !p.preambleChecked {
+			p.preambleChecked = true
+			p.err = p.parsePreamble()
+			continue
+		}
+
+		err := p.parseRecord()
+		if err == io.EOF {
+			err = p.openNext()
+		}
+		p.err = err
+	}
+	e := p.pending[0]
+	p.pending = p.pending[1:]
+	return e, nil
+}
+
+func (p *Parser) parseRecord() error {
+	b, err := p.r.Peek(1)
+	if err == io.EOF {
+		if p.inMulti {
+			// Revert the incomplete MULTI/EXEC transaction.
+			return p.truncated(p.multiOffset)
+		}
+		return io.EOF
+	}
+	if err != nil {
+		return err
+	}
+
+	start := p.offset()
+	switch b[0] {
+	case '#':
+		// Annotation, which Redis skips when loading. Only timestamps are
+		// reported.
+		line, err := p.r.ReadBytes('\n')
+		if err == io.EOF {
+			return p.truncated(p.validOffset(start))
+		}
+		if err != nil {
+			return err
+		}
+		p.base += int64(len(line))
+
+		if e := parseTimestamp(line); e != nil {
+			e.File = p.file
+			e.Offset = start
+			p.add(&RedisAofEvent{
+				EventType: EventTypeTimestamp,
+				Event:     e,
+			})
+		}
+		return nil
+	case resp.DataTypeArray:
+	default:
+		return p.formatError(start, fmt.Sprintf("expected '*', got %q", b[0]))
+	}
+
+	// ReadCommand skips an empty command, which is invalid in an AOF.
+	if n, ok := p.peekMultibulkLen(); ok && n < 1 {
+		return p.formatError(start, fmt.Sprintf("invalid argument count %d", n))
+	}
+	args, err := p.cr.ReadCommand()
+	if err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return p.truncated(p.validOffset(start))
+		}
+		var protoErr *resp.ProtocolError
+		if errors.As(err, &protoErr) {
+			return p.formatError(start, protoErr.Msg)
+		}
+		return err
+	}
+	e := &CommandEvent{
+		File:   p.file,
+		Db:     p.db,
+		Args:   args,
+		Offset: start,
+		Size:   p.offset() - start,
+	}
+
+	switch e.Name() {
+	case "SELECT":
+		if len(args) != 2 {
+			return p.formatError(start, "wrong number of arguments for SELECT")
+		}
+		db, err := strconv.Atoi(string(args[1]))
+		if err != nil || db < 0 {
+			return p.formatError(start, fmt.Sprintf("invalid database %q", args[1]))
+		}
+		p.db = db
+		return nil
+	case "MULTI":
+		// Redis rejects a nested MULTI, the block starts at the first one.
+		if !p.inMulti {
+			p.inMulti = true
+			p.multiOffset = start
+		}
+	}
+
+	p.add(&RedisAofEvent{
+		EventType: EventTypeCommand,
+		Event:     e,
+	})
+	if p.inMulti && e.Name() == "EXEC" {
+		p.pending = append(p.pending, p.multi...)
+		p.multi = nil
+		p.inMulti = false
+	}
+	return nil
+}
+
+// peekMultibulkLen returns the argument count of the command to read, ok
+// is false if the count line is incomplete or invalid, as ReadCommand
+// reports.
+func (p *Parser) peekMultibulkLen() (n int64, ok bool) {
+	b, _ := p.r.Peek(multibulkLenPeekSize)
+	i := bytes.IndexByte(b, '\n')
+	if i < 2 || b[i-1] != '\r' {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(string(b[1:i-1]), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
+
+// add queues an event, or holds it back while in a MULTI/EXEC block.
+func (p *Parser) add(e *RedisAofEvent) {
+	if p.inMulti {
+		p.multi = append(p.multi, e)
+	} else {
+		p.pending = append(p.pending, e)
+	}
+}
+
+// parseTimestamp parses a "#TS:<unix time>" annotation, written when
+// aof-timestamp-enabled is yes. It returns nil for other annotations.
+//
+// aof.c::genAofTimestampAnnotationIfNeeded
+func parseTimestamp(line []byte) *TimestampEvent {
+	line = bytes.TrimRight(line, "\r\n")
+	if !bytes.HasPrefix(line, []byte(timestampPrefix)) {
+		return nil
+	}
+	ts, err := strconv.ParseInt(string(line[len(timestampPrefix):]), 10, 64)
+	if err != nil {
+		return nil
+	}
+	return &TimestampEvent{Time: time.Unix(ts, 0)}
+}
+
+// openNext opens the next file of a multi part AOF, it returns io.EOF once
+// all files are parsed.
+func (p *Parser) openNext() error {
+	p.close()
+	if len(p.files) == 0 {
+		return io.EOF
+	}
+	p.file = p.files[0]
+	p.files = p.files[1:]
+
+	fd, err := os.Open(p.file)
+	if err != nil {
+		return err
+	}
+	p.fd = fd
+	p.reset(fd)
+	return nil
+}
+
+// parsePreamble hands the file over to rdb.Parser if it starts with an RDB,
+// as written by aof-use-rdb-preamble and for the base file of a multi part
+// AOF. The RDB parser reads exactly up to the end of the RDB, so the
+// commands after it are read from the same reader.
+//
+// aof.c::loadSingleAppendOnlyFile
+func (p *Parser) parsePreamble() error {
+	magic, err := p.r.Peek(len(rdbMagic))
+	if err != nil && err != io.EOF {
+		return err
+	}
+	if string(magic) != rdbMagic {
+		return nil
+	}
+
+	p.rdbReader = &countingReader{r: p.r}
+	rp, err := rdb.NewReaderParser(p.rdbReader)
+	if err != nil {
+		return err
+	}
+	p.rdbStreamer, err = rp.Parse()
+	return err
+}
+
+func (p *Parser) close() {
+	if p.fd != nil {
+		p.fd.Close()
+		p.fd = nil
+	}
+	p.r = nil
+	p.cr = nil
+}
+
+// validOffset returns where the valid part of the file ends, given that the
+// record at offset is incomplete.
+func (p *Parser) validOffset(offset int64) int64 {
+	if p.inMulti {
+		return p.multiOffset
+	}
+	return offset
+}
+
+func (p *Parser) truncated(validLength int64) error {
+	p.multi = nil
+	p.inMulti = false
+	// Only the last file may be truncated, the others were complete
+	// when Redis moved on to the next one.
+	if p.ignoreTruncated && len(p.files) == 0 {
+		return io.EOF
+	}
+	return &TruncatedError{File: p.file, ValidLength: validLength}
+}
+
+func (p *Parser) formatError(offset int64, reason string) error {
+	return &FormatError{
+		File:        p.file,
+		Offset:      offset,
+		ValidLength: p.validOffset(offset),
+		Reason:      reason,
+	}
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (r *countingReader) Read(b []byte) (int, error) {
+	n, err := r.r.Read(b)
+	r.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,249 @@
+package aof
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/vczyh/redis-lib/rdb"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func command(args ...string) string {
+	s := "*" + strconv.Itoa(len(args)) + "\r\n"
+	for _, arg := range args {
+		s += "$" + strconv.Itoa(len(arg)) + "\r\n" + arg + "\r\n"
+	}
+	return s
+}
+
+// collectCommands parses in and returns the commands as "db: args".
+func collectCommands(t *testing.T, in string, opts ...ParserOption) ([]string, []*CommandEvent, error) {
+	t.Helper()
+	p, err := NewParser(strings.NewReader(in), opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := p.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		commands []string
+		events   []*CommandEvent
+	)
+	for s.HasNext() {
+		e, ok := s.Next().Event.(*CommandEvent)
+		if !ok {
+			continue
+		}
+		var args []string
+		for _, arg := range e.Args {
+			args = append(args, string(arg))
+		}
+		commands = append(commands, strconv.Itoa(e.Db)+": "+strings.Join(args, " "))
+		events = append(events, e)
+	}
+	return commands, events, s.Err()
+}
+
+func TestParser(t *testing.T) {
+	in := command("SET", "a", "1") +
+		command("SELECT", "2") +
+		command("SET", "b", "2") +
+		command("select", "3") +
+		command("MULTI") +
+		command("INCR", "c") +
+		command("EXPIRE", "c", "10") +
+		command("EXEC") +
+		command("DEL", "a")
+
+	commands, events, err := collectCommands(t, in)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"0: SET a 1",
+		"2: SET b 2",
+		"3: MULTI",
+		"3: INCR c",
+		"3: EXPIRE c 10",
+		"3: EXEC",
+		"3: DEL a",
+	}, commands)
+
+	// SELECT is not reported but still takes its bytes in the file.
+	assert.Equal(t, int64(0), events[0].Offset)
+	assert.Equal(t, int64(len(command("SET", "a", "1"))), events[0].Size)
+	assert.Equal(t, int64(len(command("SET", "a", "1")+command("SELECT", "2"))), events[1].Offset)
+	last := events[len(events)-1]
+	assert.Equal(t, int64(len(in)), last.Offset+last.Size)
+}
+
+func TestParser_Annotation(t *testing.T) {
+	in := "#TS:1700000000\r\n" + command("SET", "a", "1")
+	commands, events, err := collectCommands(t, in)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"0: SET a 1"}, commands)
+	assert.Equal(t, int64(16), events[0].Offset)
+}
+
+func TestParser_Truncated(t *testing.T) {
+	complete := command("SET", "a", "1")
+	tests := []struct {
+		name        string
+		in          string
+		commands    []string
+		validLength int64
+	}{
+		{
+			name:        "command",
+			in:          complete + command("SET", "b", "2")[:12],
+			commands:    []string{"0: SET a 1"},
+			validLength: int64(len(complete)),
+		},
+		{
+			name:        "multi without exec",
+			in:          complete + command("MULTI") + command("INCR", "c"),
+			commands:    []string{"0: SET a 1"},
+			validLength: int64(len(complete)),
+		},
+		{
+			name:        "command in multi",
+			in:          complete + command("MULTI") + command("INCR", "c")[:5],
+			commands:    []string{"0: SET a 1"},
+			validLength: int64(len(complete)),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			commands, _, err := collectCommands(t, tt.in)
+			assert.Equal(t, tt.commands, commands)
+			var truncErr *TruncatedError
+			if assert.True(t, errors.As(err, &truncErr)) {
+				assert.Equal(t, tt.validLength, truncErr.ValidLength)
+			}
+
+			commands, _, err = collectCommands(t, tt.in, WithIgnoreTruncated())
+			assert.NoError(t, err)
+			assert.Equal(t, tt.commands, commands)
+		})
+	}
+}
+
+func TestParser_BadFormat(t *testing.T) {
+	for _, in := range []string{
+		"SET a 1\r\n",
+		command("SELECT", "x"),
+		"*1\r\n:1\r\n",
+	} {
+		_, _, err := collectCommands(t, command("PING")+in)
+		assert.Error(t, err, in)
+		var truncErr *TruncatedError
+		assert.False(t, errors.As(err, &truncErr), in)
+	}
+
+	// An empty command is not merged with the following one.
+	for _, in := range []string{"*0\r\n", "*-1\r\n"} {
+		commands, _, err := collectCommands(t, command("PING")+in+command("SET", "a", "1"))
+		assert.Equal(t, []string{"0: PING"}, commands, in)
+		var formatErr *FormatError
+		if assert.True(t, errors.As(err, &formatErr), in) {
+			assert.Equal(t, int64(len(command("PING"))), formatErr.Offset, in)
+		}
+	}
+}
+
+func TestParser_RdbPreamble(t *testing.T) {
+	in := rdbFile + command("SELECT", "1") + command("SET", "b", "2")
+	p, err := NewParser(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := p.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		keys []string
+		last *CommandEvent
+	)
+	for s.HasNext() {
+		switch e := s.Next().Event.(type) {
+		case *RdbEvent:
+			if o, ok := e.Event.Event.(*rdb.StringObjectEvent); ok {
+				keys = append(keys, o.Key)
+			}
+		case *CommandEvent:
+			last = e
+		}
+	}
+	assert.NoError(t, s.Err())
+	assert.Equal(t, []string{"a"}, keys)
+	if assert.NotNil(t, last) {
+		assert.Equal(t, 1, last.Db)
+		assert.Equal(t, int64(len(rdbFile)+len(command("SELECT", "1"))), last.Offset)
+		assert.Equal(t, int64(len(in)), last.Offset+last.Size)
+	}
+
+	// A truncated preamble can not be ignored.
+	p, _ = NewParser(strings.NewReader(rdbFile[:12]), WithIgnoreTruncated())
+	s, _ = p.Parse()
+	for s.HasNext() {
+	}
+	assert.ErrorContains(t, s.Err(), "RDB preamble")
+}
+
+func TestParser_ReplayUntil(t *testing.T) {
+	in := command("SET", "a", "1") +
+		"#TS:1700000000\r\n" +
+		command("SET", "b", "2") +
+		"#comment\r\n" +
+		"#TS:1700000001\r\n" +
+		command("SET", "c", "3") +
+		"#TS:1700000005\r\n" +
+		command("SET", "d", "4")
+
+	replay := func(until time.Time) ([]string, []time.Time) {
+		p, err := NewParser(strings.NewReader(in))
+		if err != nil {
+			t.Fatal(err)
+		}
+		s, err := p.Parse()
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.ReplayUntil(until)
+
+		var (
+			keys  []string
+			times []time.Time
+		)
+		for s.HasNext() {
+			switch e := s.Next().Event.(type) {
+			case *CommandEvent:
+				keys = append(keys, string(e.Args[1]))
+			case *TimestampEvent:
+				times = append(times, e.Time)
+			}
+		}
+		// The streamer stays at the cutoff.
+		assert.False(t, s.HasNext())
+		assert.NoError(t, s.Err())
+		return keys, times
+	}
+
+	keys, times := replay(time.Time{})
+	assert.Equal(t, []string{"a", "b", "c", "d"}, keys)
+	assert.Equal(t, []time.Time{time.Unix(1700000000, 0), time.Unix(1700000001, 0), time.Unix(1700000005, 0)}, times)
+
+	keys, _ = replay(time.Unix(1700000001, 0))
+	assert.Equal(t, []string{"a", "b", "c"}, keys)
+
+	keys, _ = replay(time.Unix(1700000004, 0))
+	assert.Equal(t, []string{"a", "b", "c"}, keys)
+
+	keys, _ = replay(time.Unix(1600000000, 0))
+	assert.Equal(t, []string{"a"}, keys)
+}
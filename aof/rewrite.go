@@ -0,0 +1,275 @@
+package aof
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/vczyh/redis-lib/internal/keyspace"
+	"github.com/vczyh/redis-lib/rdb"
+	"github.com/vczyh/redis-lib/resp"
+)
+
+const (
+	// Maximum number of elements per command in the rewritten AOF.
+	// server.h::AOF_REWRITE_ITEMS_PER_CMD
+	rewriteItemsPerCmd = 64
+)
+
+// RewriteReport tells how the events were applied. If Failed is not zero,
+// the model does not match the data set of Redis and must not be written.
+type RewriteReport = keyspace.Report
+
+// RewriteFailure is a command, or RDB key, the rewriter could not apply.
+type RewriteFailure = keyspace.Failure
+
+// Rewriter replays AOF events into an in-memory model of the data set, to
+// write the smallest AOF or RDB that reproduces it, as BGREWRITEAOF does.
+//
+// Commands are applied as Redis loads them: keys are not expired during the
+// replay. Relative expire times, as in EXPIRE, are based on the last
+// timestamp annotation, or the current time if there is none.
+type Rewriter struct {
+	ks     *keyspace.Keyspace
+	now    time.Time
+	report RewriteReport
+}
+
+func NewRewriter() *Rewriter {
+	rw := &Rewriter{
+		ks:     keyspace.New(),
+		report: keyspace.NewReport(),
+	}
+	rw.ks.Now = rw.clock
+	return rw
+}
+
+func (rw *Rewriter) clock() time.Time {
+	if !rw.now.IsZero() {
+		return rw.now
+	}
+	return time.Now()
+}
+
+// Apply applies an event to the model. Failures are recorded in the report.
+func (rw *Rewriter) Apply(e *RedisAofEvent) {
+	switch e := e.Event.(type) {
+	case *TimestampEvent:
+		rw.now = e.Time
+	case *CommandEvent:
+		if err := rw.ks.Apply(e.Db, e.Args); err != nil {
+			rw.report.Fail(&RewriteFailure{File: e.File, Offset: e.Offset, Db: e.Db, Args: e.Args, Name: e.Name(), Err: err})
+			return
+		}
+		rw.report.Applied++
+	case *RdbEvent:
+		rw.applyRdb(e)
+	}
+}
+
+func (rw *Rewriter) applyRdb(e *RdbEvent) {
+	// Consumer groups are not modelled, the stream would be rewritten
+	// without them.
+	if o, ok := e.Event.Event.(*rdb.StreamObjectEvent); ok && len(o.Groups) > 0 {
+		rw.report.Fail(&RewriteFailure{
+			File: e.File,
+			Db:   o.DbId,
+			Name: "rdb stream",
+			Err:  fmt.Errorf("stream key %q has consumer groups", o.Key),
+		})
+		return
+	}
+	key, v := keyspace.RdbValue(e.Event.Event)
+	if v == nil {
+		return
+	}
+	rw.ks.Set(key.DbId, key.Key, v)
+	rw.report.Applied++
+}
+
+// Report returns how the events applied so far went.
+func (rw *Rewriter) Report() *RewriteReport {
+	return &rw.report
+}
+
+// WriteAof writes commands that reproduce the data set. Keys already
+// expired are left out.
+//
+// aof.c::rewriteAppendOnlyFileRio
+func (rw *Rewriter) WriteAof(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	now := rw.clock().UnixMilli()
+	for _, db := range rw.ks.Dbs() {
+		if err := resp.WriteArray(bw, "SELECT", strconv.Itoa(db)); err != nil {
+			return err
+		}
+		for _, key := range rw.ks.Keys(db) {
+			v := rw.ks.Get(db, key)
+			if v.ExpireAt != -1 && v.ExpireAt < now {
+				continue
+			}
+			if err := writeValue(bw, key, v); err != nil {
+				return err
+			}
+			if v.ExpireAt != -1 {
+				if err := resp.WriteArray(bw, "PEXPIREAT", key, strconv.FormatInt(v.ExpireAt, 10)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+func writeValue(w io.Writer, key string, v *keyspace.Value) error {
+	switch v.Type {
+	case keyspace.TypeString:
+		return resp.WriteArray(w, "SET", key, v.String)
+	case keyspace.TypeList:
+		return writeBatches(w, "RPUSH", key, v.List, 1)
+	case keyspace.TypeSet:
+		return writeBatches(w, "SADD", key, setMembers(v), 1)
+	case keyspace.TypeZSet:
+		var items []string
+		for _, m := range v.ZMembers() {
+			items = append(items, strconv.FormatFloat(m.Score, 'g', -1, 64), m.Member)
+		}
+		return writeBatches(w, "ZADD", key, items, 2)
+	case keyspace.TypeHash:
+		var items []string
+		for _, f := range hashFields(v) {
+			items = append(items, f.Field, f.Value)
+		}
+		return writeBatches(w, "HMSET", key, items, 2)
+	case keyspace.TypeStream:
+		// aof.c::rewriteStreamObject
+		for _, e := range v.Stream.Entries {
+			args := append([]string{"XADD", key, e.ID.String()}, e.Fields...)
+			if err := resp.WriteArray(w, args...); err != nil {
+				return err
+			}
+		}
+		if len(v.Stream.Entries) == 0 {
+			// Create the empty stream.
+			args := []string{"XADD", key, "MAXLEN", "0", v.Stream.LastID.String(), "x", "y"}
+			if err := resp.WriteArray(w, args...); err != nil {
+				return err
+			}
+		}
+		return resp.WriteArray(w, "XSETID", key, v.Stream.LastID.String())
+	default:
+		return fmt.Errorf("unknown type %s", v.Type)
+	}
+}
+
+// writeBatches writes name key items... with at most rewriteItemsPerCmd
+// elements, of size items each, per command.
+func writeBatches(w io.Writer, name, key string, items []string, size int) error {
+	batch := rewriteItemsPerCmd * size
+	for len(items) > 0 {
+		n := batch
+		if n > len(items) {
+			n = len(items)
+		}
+		args := append([]string{name, key}, items[:n]...)
+		if err := resp.WriteArray(w, args...); err != nil {
+			return err
+		}
+		items = items[n:]
+	}
+	return nil
+}
+
+// WriteRdb writes an RDB holding the data set. Keys already expired are
+// left out.
+func (rw *Rewriter) WriteRdb(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	rdbWriter := rdb.NewWriter(bw)
+	if err := rdbWriter.WriteHeader(); err != nil {
+		return err
+	}
+	now := rw.clock().UnixMilli()
+	for _, db := range rw.ks.Dbs() {
+		if err := rdbWriter.SelectDb(db); err != nil {
+			return err
+		}
+		for _, key := range rw.ks.Keys(db) {
+			v := rw.ks.Get(db, key)
+			if v.ExpireAt != -1 && v.ExpireAt < now {
+				continue
+			}
+			if err := writeRdbValue(rdbWriter, key, v); err != nil {
+				return err
+			}
+		}
+	}
+	if err := rdbWriter.Close(); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+func writeRdbValue(w *rdb.Writer, key string, v *keyspace.Value) error {
+	switch v.Type {
+	case keyspace.TypeString:
+		return w.WriteString(key, v.String, v.ExpireAt)
+	case keyspace.TypeList:
+		return w.WriteList(key, v.List, v.ExpireAt)
+	case keyspace.TypeSet:
+		return w.WriteSet(key, setMembers(v), v.ExpireAt)
+	case keyspace.TypeZSet:
+		var members []rdb.ZSetMember
+		for _, m := range v.ZMembers() {
+			members = append(members, rdb.ZSetMember{Value: m.Member, Score: m.Score})
+		}
+		return w.WriteZSet(key, members, v.ExpireAt)
+	case keyspace.TypeHash:
+		return w.WriteHash(key, hashFields(v), v.ExpireAt)
+	case keyspace.TypeStream:
+		return fmt.Errorf("stream key %q can not be written to an RDB", key)
+	default:
+		return fmt.Errorf("unknown type %s", v.Type)
+	}
+}
+
+// setMembers and hashFields sort the elements, so that rewriting the same
+// data set gives the same file.
+func setMembers(v *keyspace.Value) []string {
+	members := make([]string, 0, len(v.Set))
+	for m := range v.Set {
+		members = append(members, m)
+	}
+	sort.Strings(members)
+	return members
+}
+
+func hashFields(v *keyspace.Value) []rdb.HashField {
+	fields := make([]rdb.HashField, 0, len(v.Hash))
+	for f, value := range v.Hash {
+		fields = append(fields, rdb.HashField{Field: f, Value: value})
+	}
+	sort.Slice(fields, func(i, j int) bool {
+		return fields[i].Field < fields[j].Field
+	})
+	return fields
+}
+
+// Rewrite replays s and writes the rewritten AOF to w. Nothing is written
+// if an event could not be applied, the report tells which.
+func Rewrite(s *EventStreamer, w io.Writer) (*RewriteReport, error) {
+	rw := NewRewriter()
+	for s.HasNext() {
+		rw.Apply(s.Next())
+	}
+	if err := s.Err(); err != nil {
+		return rw.Report(), err
+	}
+	report := rw.Report()
+	if report.Failed > 0 {
+		return report, fmt.Errorf("%d commands could not be applied, first: %w", report.Failed, report.Failures[0])
+	}
+	return report, rw.WriteAof(w)
+}
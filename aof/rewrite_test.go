@@ -0,0 +1,146 @@
+package aof
+
+import (
+	"bytes"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/vczyh/redis-lib/internal/keyspace"
+	"github.com/vczyh/redis-lib/rdb"
+	"strings"
+	"testing"
+)
+
+func TestRewrite(t *testing.T) {
+	in := rdbFile +
+		"#TS:1700000000\r\n" +
+		command("SET", "b", "2") +
+		command("EXPIRE", "b", "100") +
+		command("RENAME", "a", "c") +
+		command("SELECT", "1") +
+		command("RPUSH", "l", "x", "y") +
+		command("HSET", "h", "f", "v") +
+		command("SET", "gone", "v") +
+		command("PEXPIREAT", "gone", "1000") +
+		command("SELECT", "2") +
+		command("SADD", "s", "m") +
+		command("FLUSHDB") +
+		command("SELECT", "3") +
+		command("ZADD", "z", "1.5", "m") +
+		command("XADD", "x", "1-1", "f", "v") +
+		command("XADD", "x", "2-1", "f", "w") +
+		command("XDEL", "x", "2-1")
+
+	p, err := NewParser(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, _ := p.Parse()
+	var out bytes.Buffer
+	report, err := Rewrite(s, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 14, report.Applied)
+	assert.Equal(t, 0, report.Failed)
+
+	commands, _, err := collectCommands(t, out.String())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"0: SET b 2",
+		"0: PEXPIREAT b 1700000100000",
+		"0: SET c 1",
+		"1: HMSET h f v",
+		"1: RPUSH l x y",
+		"3: XADD x 1-1 f v",
+		"3: XSETID x 2-1",
+		"3: ZADD z 1.5 m",
+	}, commands)
+}
+
+func TestRewrite_Unsupported(t *testing.T) {
+	in := command("SET", "a", "1") +
+		command("EVAL", "redis.call('set', KEYS[1], 2)", "1", "a") +
+		command("SADD", "a", "m")
+	p, _ := NewParser(strings.NewReader(in))
+	s, _ := p.Parse()
+
+	var out bytes.Buffer
+	report, err := Rewrite(s, &out)
+	assert.Error(t, err)
+	assert.Zero(t, out.Len())
+	assert.Equal(t, 1, report.Applied)
+	assert.Equal(t, 2, report.Failed)
+	assert.Equal(t, map[string]int{"EVAL": 1, "SADD": 1}, report.FailedByName)
+
+	var unsupported *keyspace.UnsupportedCommandError
+	assert.True(t, errors.As(report.Failures[0].Err, &unsupported))
+	assert.Equal(t, int64(len(command("SET", "a", "1"))), report.Failures[0].Offset)
+	assert.ErrorIs(t, report.Failures[1].Err, keyspace.ErrWrongType)
+}
+
+func TestRewriter_WriteRdb(t *testing.T) {
+	in := command("SET", "a", "1") +
+		command("PEXPIREAT", "a", "4000000000000") +
+		command("SELECT", "5") +
+		command("SADD", "s", "y", "x")
+	p, _ := NewParser(strings.NewReader(in))
+	s, _ := p.Parse()
+	rw := NewRewriter()
+	for s.HasNext() {
+		rw.Apply(s.Next())
+	}
+	assert.NoError(t, s.Err())
+
+	var out bytes.Buffer
+	assert.NoError(t, rw.WriteRdb(&out))
+
+	rp, err := rdb.NewReaderParser(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs, _ := rp.Parse()
+	var keys []string
+	for rs.HasNext() {
+		switch o := rs.Next().Event.(type) {
+		case *rdb.StringObjectEvent:
+			assert.Equal(t, int64(4000000000000), o.ExpireAt())
+			keys = append(keys, o.Key)
+		case *rdb.SetObjectEvent:
+			assert.Equal(t, 5, o.DbId)
+			assert.Equal(t, []string{"x", "y"}, o.Members)
+			keys = append(keys, o.Key)
+		}
+	}
+	assert.NoError(t, rs.Err())
+	assert.Equal(t, []string{"a", "s"}, keys)
+}
+
+func TestRewriter_RdbStream(t *testing.T) {
+	stream := func(key string, fields []rdb.StreamField, groups []*rdb.StreamConsumerGroup) *RedisAofEvent {
+		return &RedisAofEvent{EventType: EventTypeRdb, Event: &RdbEvent{Event: &rdb.RedisRdbEvent{
+			EventType: rdb.EventTypeStreamObject,
+			Event: &rdb.StreamObjectEvent{
+				RedisKey: rdb.RedisKey{Key: key},
+				Entries:  []*rdb.StreamEntry{{Id: rdb.StreamId{Ms: 1, Seq: 1}, Fields: fields}},
+				Groups:   groups,
+				LastId:   rdb.StreamId{Ms: 1, Seq: 1},
+			},
+		}}}
+	}
+	rw := NewRewriter()
+	rw.Apply(stream("a", []rdb.StreamField{{Field: "g", Value: "w"}, {Field: "f", Value: "v"}}, nil))
+	rw.Apply(stream("b", []rdb.StreamField{{Field: "f", Value: "v"}}, []*rdb.StreamConsumerGroup{{Name: "g"}}))
+
+	report := rw.Report()
+	assert.Equal(t, 1, report.Applied)
+	assert.Equal(t, 1, report.Failed)
+	assert.Equal(t, map[string]int{"rdb stream": 1}, report.FailedByName)
+
+	// The RedisKey built here has an expire time in the past, the data set
+	// is checked rather than the rewritten AOF.
+	v := rw.ks.Get(0, "a")
+	if assert.NotNil(t, v) {
+		assert.Equal(t, []keyspace.StreamEntry{{ID: keyspace.StreamID{Ms: 1, Seq: 1}, Fields: []string{"g", "w", "f", "v"}}}, v.Stream.Entries)
+	}
+	assert.Nil(t, rw.ks.Get(0, "b"))
+}
@@ -0,0 +1,61 @@
+package aof
+
+import (
+	"io"
+	"time"
+)
+
+type EventStreamer struct {
+	p   *Parser
+	o   *RedisAofEvent
+	err error
+
+	// Stop at the first timestamp after until, see ReplayUntil.
+	until time.Time
+	done  bool
+}
+
+func newEventStreamer(p *Parser) *EventStreamer {
+	return &EventStreamer{
+		p: p,
+	}
+}
+
+func (s *EventStreamer) HasNext() bool {
+	if s.err != nil || s.done {
+		return false
+	}
+
+	e, err := s.p.next()
+	if err != nil {
+		if err != io.EOF {
+			s.err = err
+		}
+		return false
+	}
+	if ts, ok := e.Event.(*TimestampEvent); ok && !s.until.IsZero() && ts.Time.After(s.until) {
+		s.done = true
+		return false
+	}
+	s.o = e
+	return true
+}
+
+// ReplayUntil makes the streamer end before the first timestamp annotation
+// later than t, so that only commands executed at t or earlier are returned,
+// for point-in-time recovery as redis-check-aof --truncate-to-timestamp
+// does. The events of the RDB preamble or base file are always returned.
+// Commands are only as precise as the annotations, which Redis writes at
+// most once per second.
+func (s *EventStreamer) ReplayUntil(t time.Time) *EventStreamer {
+	s.until = t
+	return s
+}
+
+func (s *EventStreamer) Next() *RedisAofEvent {
+	return s.o
+}
+
+func (s *EventStreamer) Err() error {
+	return s.err
+}
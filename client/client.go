@@ -1,6 +1,7 @@
 package client
 
 import (
+	"context"
 	"fmt"
 	"github.com/vczyh/redis-lib/connection"
 	"net"
@@ -21,10 +22,16 @@ type Config struct {
 }
 
 func NewClient(config *Config) (*Client, error) {
+	return NewClientContext(context.Background(), config)
+}
+
+// NewClientContext is like NewClient, ctx stops connecting to the server.
+func NewClientContext(ctx context.Context, config *Config) (*Client, error) {
 	c := &Client{
 		config: config,
 	}
-	nc, err := net.Dial("tcp", net.JoinHostPort(config.Host, strconv.Itoa(config.Port)))
+	var d net.Dialer
+	nc, err := d.DialContext(ctx, "tcp", net.JoinHostPort(config.Host, strconv.Itoa(config.Port)))
 	if err != nil {
 		return nil, err
 	}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"github.com/vczyh/redis-lib/resp"
 	"net"
+	"time"
 )
 
 type Conn struct {
@@ -64,6 +65,16 @@ func (c *Conn) ReadData() ([]byte, error) {
 	return resp.ReadData(c.Reader)
 }
 
+// SetReadDeadline makes the blocked and future reads fail after t, see
+// net.Conn.
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	return c.nc.SetReadDeadline(t)
+}
+
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	return c.nc.SetWriteDeadline(t)
+}
+
 func (c *Conn) Close() error {
 	return c.nc.Close()
 }
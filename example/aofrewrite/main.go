@@ -0,0 +1,80 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/vczyh/redis-lib/aof"
+)
+
+// Compact an AOF file or a Redis 7 appendonlydir, e.g.
+//
+//	go run ./example/aofrewrite -o /tmp/compact.aof /var/lib/redis/appendonlydir
+func main() {
+	output := flag.String("o", "rewritten.aof", "output file")
+	asRdb := flag.Bool("rdb", false, "write an RDB instead of an AOF")
+	flag.Parse()
+	if flag.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: aofrewrite [-o output] [-rdb] <appendonly.aof | appendonlydir>")
+		os.Exit(2)
+	}
+
+	p, err := open(flag.Arg(0))
+	if err != nil {
+		panic(err)
+	}
+	s, err := p.Parse()
+	if err != nil {
+		panic(err)
+	}
+
+	rw := aof.NewRewriter()
+	for s.HasNext() {
+		rw.Apply(s.Next())
+	}
+	if err := s.Err(); err != nil {
+		panic(err)
+	}
+
+	report := rw.Report()
+	if report.Failed > 0 {
+		for name, n := range report.FailedByName {
+			fmt.Fprintf(os.Stderr, "%s: %d not applied\n", name, n)
+		}
+		for _, f := range report.Failures {
+			fmt.Fprintln(os.Stderr, f)
+		}
+		os.Exit(1)
+	}
+
+	f, err := os.Create(*output)
+	if err != nil {
+		panic(err)
+	}
+	defer f.Close()
+	if *asRdb {
+		err = rw.WriteRdb(f)
+	} else {
+		err = rw.WriteAof(f)
+	}
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("%d commands and keys applied\n", report.Applied)
+}
+
+func open(path string) (*aof.Parser, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if fi.IsDir() {
+		return aof.OpenDir(path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return aof.NewParser(f)
+}
@@ -1,55 +1,40 @@
 package main
 
 import (
-	"github.com/vczyh/redis-lib/rdb"
+	"context"
+	"fmt"
 	"github.com/vczyh/redis-lib/replica"
-	"io"
 	"os"
+	"os/signal"
 )
 
 func main() {
-	rdbReader, rdbWriter := io.Pipe()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
 
-	r, err := replica.NewReplica(&replica.Config{
-		MasterIP:       "127.0.0.1",
-		MasterPort:     26379,
-		MasterUser:     "",
-		MasterPassword: "123",
-		RdbWriter:      rdbWriter,
-		AofWriter:      os.Stdout,
-		//ContinueAfterFullSync: true,
-
-		MasterReplicaId:         "aaa",
-		MasterReplicaOffset:     22,
-		ContinueIfPartialFailed: true,
+	s, err := replica.NewStream(ctx, &replica.Config{
+		MasterIP:              "127.0.0.1",
+		MasterPort:            26379,
+		MasterUser:            "",
+		MasterPassword:        "123",
+		ContinueAfterFullSync: true,
 	})
 	if err != nil {
 		panic(err)
 	}
+	defer s.Close()
 
-	go func() {
-		// Parse rbd from master.
-		if err := parseRdb(rdbReader); err != nil {
-			panic(err)
-		}
-	}()
-
-	if err = r.SyncWithMaster(); err != nil {
-		panic(err)
-	}
-}
-func parseRdb(r io.Reader) error {
-	p, err := rdb.NewReaderParser(r)
-	if err != nil {
-		return err
-	}
-	s, err := p.Parse()
-	if err != nil {
-		return err
-	}
+	// Parse rdb from master, then the commands replicated.
 	for s.HasNext() {
 		e := s.Next()
-		e.Event.Debug()
+		switch e.Type {
+		case replica.StreamEventTypeRdb:
+			e.Rdb.Event.Debug()
+		case replica.StreamEventTypeCommand:
+			fmt.Printf("db %d: %q\n", e.Command.Db, e.Command.Args)
+		}
+	}
+	if err := s.Err(); err != nil && ctx.Err() == nil {
+		panic(err)
 	}
-	return s.Err()
 }
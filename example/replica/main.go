@@ -1,8 +1,11 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"github.com/vczyh/redis-lib/replica"
 	"os"
+	"os/signal"
 )
 
 func main() {
@@ -19,7 +22,11 @@ func main() {
 		panic(err)
 	}
 
-	if err := r.SyncWithMaster(); err != nil {
+	// Stop gracefully on Ctrl+C.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if err := r.SyncWithMaster(ctx); err != nil && !errors.Is(err, context.Canceled) {
 		panic(err)
 	}
 }
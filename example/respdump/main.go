@@ -0,0 +1,41 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/vczyh/redis-lib/resp"
+)
+
+// Print a captured RESP byte stream, e.g.
+//
+//	printf '*2\r\n$3\r\nGET\r\n$1\r\nk\r\n' | go run ./example/respdump
+func main() {
+	compact := flag.Bool("compact", false, "print one frame per line")
+	flag.Parse()
+
+	style := resp.FormatCLI
+	if *compact {
+		style = resp.FormatCompact
+	}
+
+	r := bufio.NewReader(os.Stdin)
+	for {
+		v, err := resp.ReadValue(r)
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			panic(err)
+		}
+
+		if style == resp.FormatCompact {
+			fmt.Println(resp.Format(v, style))
+		} else {
+			fmt.Print(resp.Format(v, style))
+		}
+	}
+}
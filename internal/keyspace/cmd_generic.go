@@ -0,0 +1,168 @@
+package keyspace
+
+import (
+	"strconv"
+	"strings"
+)
+
+func delCommand(ks *Keyspace, c *command) error {
+	for _, key := range c.args[1:] {
+		delete(ks.dbs[c.db], key)
+	}
+	return nil
+}
+
+// EXPIRE key seconds [NX | XX | GT | LT], and PEXPIRE, EXPIREAT, PEXPIREAT.
+//
+// expire.c::expireGenericCommand
+func expireCommand(ks *Keyspace, c *command) error {
+	var unit string
+	switch c.name {
+	case "expire":
+		unit = "EX"
+	case "pexpire":
+		unit = "PX"
+	case "expireat":
+		unit = "EXAT"
+	default:
+		unit = "PXAT"
+	}
+	when, err := ks.expireTime(unit, c.args[2])
+	if err != nil {
+		return err
+	}
+
+	var nx, xx, gt, lt bool
+	for _, opt := range c.args[3:] {
+		switch strings.ToUpper(opt) {
+		case "NX":
+			nx = true
+		case "XX":
+			xx = true
+		case "GT":
+			gt = true
+		case "LT":
+			lt = true
+		default:
+			return errSyntax
+		}
+	}
+
+	v := ks.dbs[c.db][c.args[1]]
+	if v == nil {
+		return nil
+	}
+	// A key without expire time has an infinite TTL for GT and LT.
+	switch {
+	case nx && v.ExpireAt != -1,
+		xx && v.ExpireAt == -1,
+		gt && (v.ExpireAt == -1 || when <= v.ExpireAt),
+		lt && v.ExpireAt != -1 && when >= v.ExpireAt:
+		return nil
+	}
+	v.ExpireAt = when
+	return nil
+}
+
+func persistCommand(ks *Keyspace, c *command) error {
+	if v := ks.dbs[c.db][c.args[1]]; v != nil {
+		v.ExpireAt = -1
+	}
+	return nil
+}
+
+// RENAME key newkey, and RENAMENX.
+func renameCommand(ks *Keyspace, c *command) error {
+	src, dst := c.args[1], c.args[2]
+	keys := ks.dbs[c.db]
+	v := keys[src]
+	if v == nil {
+		return errNoSuchKey
+	}
+	if src == dst {
+		return nil
+	}
+	if c.name == "renamenx" && keys[dst] != nil {
+		return nil
+	}
+	delete(keys, src)
+	keys[dst] = v
+	return nil
+}
+
+// MOVE key db
+func moveCommand(ks *Keyspace, c *command) error {
+	dstDb, err := strconv.Atoi(c.args[2])
+	if err != nil || dstDb < 0 {
+		return errNotInteger
+	}
+	v := ks.dbs[c.db][c.args[1]]
+	if v == nil || dstDb == c.db || ks.dbs[dstDb][c.args[1]] != nil {
+		return nil
+	}
+	delete(ks.dbs[c.db], c.args[1])
+	ks.db(dstDb)[c.args[1]] = v
+	return nil
+}
+
+// COPY source destination [DB destination-db] [REPLACE]
+func copyCommand(ks *Keyspace, c *command) error {
+	dstDb := c.db
+	var replace bool
+	for i := 3; i < len(c.args); i++ {
+		switch {
+		case strings.EqualFold(c.args[i], "REPLACE"):
+			replace = true
+		case strings.EqualFold(c.args[i], "DB") && i+1 < len(c.args):
+			db, err := strconv.Atoi(c.args[i+1])
+			if err != nil || db < 0 {
+				return errNotInteger
+			}
+			dstDb = db
+			i++
+		default:
+			return errSyntax
+		}
+	}
+
+	v := ks.dbs[c.db][c.args[1]]
+	if v == nil || (dstDb == c.db && c.args[1] == c.args[2]) {
+		return nil
+	}
+	if ks.dbs[dstDb][c.args[2]] != nil && !replace {
+		return nil
+	}
+	ks.db(dstDb)[c.args[2]] = v.clone()
+	return nil
+}
+
+// FLUSHDB [ASYNC | SYNC]
+func flushdbCommand(ks *Keyspace, c *command) error {
+	delete(ks.dbs, c.db)
+	return nil
+}
+
+// FLUSHALL [ASYNC | SYNC]
+func flushallCommand(ks *Keyspace, c *command) error {
+	ks.dbs = make(map[int]map[string]*Value)
+	return nil
+}
+
+// SWAPDB index1 index2
+func swapdbCommand(ks *Keyspace, c *command) error {
+	db1, err := strconv.Atoi(c.args[1])
+	if err != nil || db1 < 0 {
+		return errNotInteger
+	}
+	db2, err := strconv.Atoi(c.args[2])
+	if err != nil || db2 < 0 {
+		return errNotInteger
+	}
+	ks.dbs[db1], ks.dbs[db2] = ks.dbs[db2], ks.dbs[db1]
+	for _, db := range []int{db1, db2} {
+		if ks.dbs[db] == nil {
+			delete(ks.dbs, db)
+		}
+	}
+	return nil
+}
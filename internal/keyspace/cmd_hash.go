@@ -0,0 +1,96 @@
+package keyspace
+
+import (
+	"math"
+	"strconv"
+)
+
+// HSET key field value [field value ...], and HMSET.
+func hsetCommand(ks *Keyspace, c *command) error {
+	if len(c.args)%2 != 0 {
+		return errWrongArgs(c.name)
+	}
+	v, err := ks.lookupOrCreate(c.db, c.args[1], TypeHash)
+	if err != nil {
+		return err
+	}
+	for i := 2; i < len(c.args); i += 2 {
+		v.Hash[c.args[i]] = c.args[i+1]
+	}
+	return nil
+}
+
+func hsetnxCommand(ks *Keyspace, c *command) error {
+	v, err := ks.lookupOrCreate(c.db, c.args[1], TypeHash)
+	if err != nil {
+		return err
+	}
+	if _, ok := v.Hash[c.args[2]]; !ok {
+		v.Hash[c.args[2]] = c.args[3]
+	}
+	return nil
+}
+
+func hdelCommand(ks *Keyspace, c *command) error {
+	v, err := ks.lookup(c.db, c.args[1], TypeHash)
+	if err != nil || v == nil {
+		return err
+	}
+	for _, f := range c.args[2:] {
+		delete(v.Hash, f)
+	}
+	ks.deleteIfEmpty(c.db, c.args[1], v)
+	return nil
+}
+
+// HINCRBY key field increment
+func hincrbyCommand(ks *Keyspace, c *command) error {
+	incr, err := parseInt(c.args[3])
+	if err != nil {
+		return err
+	}
+	v, err := ks.lookupOrCreate(c.db, c.args[1], TypeHash)
+	if err != nil {
+		return err
+	}
+	var current int64
+	if s, ok := v.Hash[c.args[2]]; ok {
+		if current, err = parseInt(s); err != nil {
+			ks.deleteIfEmpty(c.db, c.args[1], v)
+			return errHashNotInteger
+		}
+	}
+	if (incr < 0 && current < 0 && incr < math.MinInt64-current) ||
+		(incr > 0 && current > 0 && incr > math.MaxInt64-current) {
+		ks.deleteIfEmpty(c.db, c.args[1], v)
+		return errOverflow
+	}
+	v.Hash[c.args[2]] = strconv.FormatInt(current+incr, 10)
+	return nil
+}
+
+// HINCRBYFLOAT key field increment
+func hincrbyfloatCommand(ks *Keyspace, c *command) error {
+	incr, err := parseFloat(c.args[3])
+	if err != nil {
+		return err
+	}
+	v, err := ks.lookupOrCreate(c.db, c.args[1], TypeHash)
+	if err != nil {
+		return err
+	}
+	var current float64
+	if s, ok := v.Hash[c.args[2]]; ok {
+		if current, err = parseFloat(s); err != nil {
+			ks.deleteIfEmpty(c.db, c.args[1], v)
+			return errHashNotFloat
+		}
+	}
+	result := current + incr
+	if math.IsInf(result, 0) {
+		ks.deleteIfEmpty(c.db, c.args[1], v)
+		return errNotFloat
+	}
+	v.Hash[c.args[2]] = formatFloat(result)
+	return nil
+}
@@ -0,0 +1,251 @@
+package keyspace
+
+import (
+	"strings"
+)
+
+// PUSH key element [element ...], LPUSH, RPUSH, LPUSHX and RPUSHX.
+func pushCommand(ks *Keyspace, c *command) error {
+	var (
+		v   *Value
+		err error
+	)
+	if strings.HasSuffix(c.name, "x") {
+		v, err = ks.lookup(c.db, c.args[1], TypeList)
+		if err != nil || v == nil {
+			return err
+		}
+	} else {
+		v, err = ks.lookupOrCreate(c.db, c.args[1], TypeList)
+		if err != nil {
+			return err
+		}
+	}
+
+	if c.name[0] == 'r' {
+		v.List = append(v.List, c.args[2:]...)
+		return nil
+	}
+	// Each element is pushed to the head in turn.
+	list := make([]string, 0, len(c.args)-2+len(v.List))
+	for i := len(c.args) - 1; i >= 2; i-- {
+		list = append(list, c.args[i])
+	}
+	v.List = append(list, v.List...)
+	return nil
+}
+
+// LPOP key [count], and RPOP.
+func popCommand(ks *Keyspace, c *command) error {
+	if len(c.args) > 3 {
+		return errSyntax
+	}
+	count := int64(1)
+	if len(c.args) == 3 {
+		n, err := parseInt(c.args[2])
+		if err != nil || n < 0 {
+			return errNotInteger
+		}
+		count = n
+	}
+
+	v, err := ks.lookup(c.db, c.args[1], TypeList)
+	if err != nil || v == nil {
+		return err
+	}
+	if count > int64(len(v.List)) {
+		count = int64(len(v.List))
+	}
+	if c.name == "lpop" {
+		v.List = v.List[count:]
+	} else {
+		v.List = v.List[:int64(len(v.List))-count]
+	}
+	ks.deleteIfEmpty(c.db, c.args[1], v)
+	return nil
+}
+
+// LSET key index element
+func lsetCommand(ks *Keyspace, c *command) error {
+	index, err := parseInt(c.args[2])
+	if err != nil {
+		return err
+	}
+	v, err := ks.lookup(c.db, c.args[1], TypeList)
+	if err != nil {
+		return err
+	}
+	if v == nil {
+		return errNoSuchKey
+	}
+	if index < 0 {
+		index += int64(len(v.List))
+	}
+	if index < 0 || index >= int64(len(v.List)) {
+		return errIndexOutOfRange
+	}
+	v.List[index] = c.args[3]
+	return nil
+}
+
+// LTRIM key start stop
+func ltrimCommand(ks *Keyspace, c *command) error {
+	start, err := parseInt(c.args[2])
+	if err != nil {
+		return err
+	}
+	stop, err := parseInt(c.args[3])
+	if err != nil {
+		return err
+	}
+	v, err := ks.lookup(c.db, c.args[1], TypeList)
+	if err != nil || v == nil {
+		return err
+	}
+
+	from, to, ok := rangeIndexes(start, stop, len(v.List))
+	if !ok {
+		v.List = nil
+	} else {
+		v.List = append([]string(nil), v.List[from:to+1]...)
+	}
+	ks.deleteIfEmpty(c.db, c.args[1], v)
+	return nil
+}
+
+// LREM key count element
+func lremCommand(ks *Keyspace, c *command) error {
+	count, err := parseInt(c.args[2])
+	if err != nil {
+		return err
+	}
+	v, err := ks.lookup(c.db, c.args[1], TypeList)
+	if err != nil || v == nil {
+		return err
+	}
+
+	keep := make([]bool, len(v.List))
+	for i := range keep {
+		keep[i] = true
+	}
+	removed := int64(0)
+	if count >= 0 {
+		for i := 0; i < len(v.List) && (count == 0 || removed < count); i++ {
+			if v.List[i] == c.args[3] {
+				keep[i] = false
+				removed++
+			}
+		}
+	} else {
+		for i := len(v.List) - 1; i >= 0 && removed < -count; i-- {
+			if v.List[i] == c.args[3] {
+				keep[i] = false
+				removed++
+			}
+		}
+	}
+
+	list := make([]string, 0, len(v.List)-int(removed))
+	for i, e := range v.List {
+		if keep[i] {
+			list = append(list, e)
+		}
+	}
+	v.List = list
+	ks.deleteIfEmpty(c.db, c.args[1], v)
+	return nil
+}
+
+// LINSERT key <BEFORE | AFTER> pivot element
+func linsertCommand(ks *Keyspace, c *command) error {
+	var after bool
+	switch strings.ToUpper(c.args[2]) {
+	case "BEFORE":
+	case "AFTER":
+		after = true
+	default:
+		return errSyntax
+	}
+	v, err := ks.lookup(c.db, c.args[1], TypeList)
+	if err != nil || v == nil {
+		return err
+	}
+
+	for i, e := range v.List {
+		if e != c.args[3] {
+			continue
+		}
+		if after {
+			i++
+		}
+		v.List = append(v.List[:i], append([]string{c.args[4]}, v.List[i:]...)...)
+		return nil
+	}
+	return nil
+}
+
+// LMOVE source destination <LEFT | RIGHT> <LEFT | RIGHT>, and
+// RPOPLPUSH source destination.
+func lmoveCommand(ks *Keyspace, c *command) error {
+	from, to := "RIGHT", "LEFT"
+	if c.name == "lmove" {
+		from, to = strings.ToUpper(c.args[3]), strings.ToUpper(c.args[4])
+		for _, where := range []string{from, to} {
+			if where != "LEFT" && where != "RIGHT" {
+				return errSyntax
+			}
+		}
+	}
+
+	src, err := ks.lookup(c.db, c.args[1], TypeList)
+	if err != nil || src == nil {
+		return err
+	}
+	if _, err := ks.lookup(c.db, c.args[2], TypeList); err != nil {
+		return err
+	}
+
+	var e string
+	if from == "LEFT" {
+		e, src.List = src.List[0], src.List[1:]
+	} else {
+		e, src.List = src.List[len(src.List)-1], src.List[:len(src.List)-1]
+	}
+	ks.deleteIfEmpty(c.db, c.args[1], src)
+
+	dst, err := ks.lookupOrCreate(c.db, c.args[2], TypeList)
+	if err != nil {
+		return err
+	}
+	if to == "LEFT" {
+		dst.List = append([]string{e}, dst.List...)
+	} else {
+		dst.List = append(dst.List, e)
+	}
+	return nil
+}
+
+// rangeIndexes converts the start and stop of a range command, which may be
+// negative, to indexes of a sequence of length n. ok is false if the range
+// is empty.
+//
+// t_list.c::ltrimCommand
+func rangeIndexes(start, stop int64, n int) (from, to int, ok bool) {
+	length := int64(n)
+	if start < 0 {
+		start += length
+	}
+	if stop < 0 {
+		stop += length
+	}
+	if start < 0 {
+		start = 0
+	}
+	if start > stop || start >= length {
+		return 0, 0, false
+	}
+	if stop >= length {
+		stop = length - 1
+	}
+	return int(start), int(stop), true
+}
@@ -0,0 +1,106 @@
+package keyspace
+
+func saddCommand(ks *Keyspace, c *command) error {
+	v, err := ks.lookupOrCreate(c.db, c.args[1], TypeSet)
+	if err != nil {
+		return err
+	}
+	for _, m := range c.args[2:] {
+		v.Set[m] = struct{}{}
+	}
+	return nil
+}
+
+func sremCommand(ks *Keyspace, c *command) error {
+	v, err := ks.lookup(c.db, c.args[1], TypeSet)
+	if err != nil || v == nil {
+		return err
+	}
+	for _, m := range c.args[2:] {
+		delete(v.Set, m)
+	}
+	ks.deleteIfEmpty(c.db, c.args[1], v)
+	return nil
+}
+
+// SMOVE source destination member
+func smoveCommand(ks *Keyspace, c *command) error {
+	src, err := ks.lookup(c.db, c.args[1], TypeSet)
+	if err != nil {
+		return err
+	}
+	if _, err := ks.lookup(c.db, c.args[2], TypeSet); err != nil {
+		return err
+	}
+	if src == nil {
+		return nil
+	}
+	if _, ok := src.Set[c.args[3]]; !ok {
+		return nil
+	}
+
+	delete(src.Set, c.args[3])
+	ks.deleteIfEmpty(c.db, c.args[1], src)
+	dst, err := ks.lookupOrCreate(c.db, c.args[2], TypeSet)
+	if err != nil {
+		return err
+	}
+	dst.Set[c.args[3]] = struct{}{}
+	return nil
+}
+
+// SINTERSTORE destination key [key ...], and SUNIONSTORE, SDIFFSTORE.
+//
+// t_set.c::sinterGenericCommand, t_set.c::sunionDiffGenericCommand
+func setOpStoreCommand(ks *Keyspace, c *command) error {
+	sets := make([]map[string]struct{}, 0, len(c.args)-2)
+	for _, key := range c.args[2:] {
+		v, err := ks.lookup(c.db, key, TypeSet)
+		if err != nil {
+			return err
+		}
+		var set map[string]struct{}
+		if v != nil {
+			set = v.Set
+		}
+		sets = append(sets, set)
+	}
+
+	result := make(map[string]struct{})
+	switch c.name {
+	case "sinterstore":
+		for m := range sets[0] {
+			in := true
+			for _, set := range sets[1:] {
+				if _, ok := set[m]; !ok {
+					in = false
+					break
+				}
+			}
+			if in {
+				result[m] = struct{}{}
+			}
+		}
+	case "sunionstore":
+		for _, set := range sets {
+			for m := range set {
+				result[m] = struct{}{}
+			}
+		}
+	case "sdiffstore":
+		for m := range sets[0] {
+			result[m] = struct{}{}
+		}
+		for _, set := range sets[1:] {
+			for m := range set {
+				delete(result, m)
+			}
+		}
+	}
+
+	delete(ks.dbs[c.db], c.args[1])
+	if len(result) > 0 {
+		ks.db(c.db)[c.args[1]] = &Value{Type: TypeSet, Set: result, ExpireAt: -1}
+	}
+	return nil
+}
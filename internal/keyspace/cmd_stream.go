@@ -0,0 +1,319 @@
+package keyspace
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// StreamID is the ID of a stream entry, written <ms>-<seq>.
+type StreamID struct {
+	Ms  uint64
+	Seq uint64
+}
+
+func (id StreamID) String() string {
+	return strconv.FormatUint(id.Ms, 10) + "-" + strconv.FormatUint(id.Seq, 10)
+}
+
+func (id StreamID) less(o StreamID) bool {
+	return id.Ms < o.Ms || (id.Ms == o.Ms && id.Seq < o.Seq)
+}
+
+// StreamEntry is an entry of a stream, Fields alternates field names and
+// values.
+type StreamEntry struct {
+	ID     StreamID
+	Fields []string
+}
+
+// Stream is the value of a stream key. Consumer groups are not modelled, and
+// a stream is not deleted when its last entry is, as in Redis.
+type Stream struct {
+	// Entries in ascending ID order.
+	Entries []StreamEntry
+
+	// Greatest ID ever added, entries up to it may have been deleted.
+	LastID StreamID
+}
+
+var (
+	errInvalidStreamID   = errors.New("ERR Invalid stream ID specified as stream command argument")
+	errStreamIDTooSmall  = errors.New("ERR The ID specified in XADD is equal or smaller than the target stream top item")
+	errStreamIDZero      = errors.New("ERR The ID specified in XADD must be greater than 0-0")
+	errStreamSetIDSmall  = errors.New("ERR The ID specified in XSETID is smaller than the target stream top item")
+	errStreamIDExhausted = errors.New("ERR The stream has exhausted the last possible ID, unable to add more items")
+)
+
+// parseStreamID parses <ms>-<seq>, or <ms> alone with a sequence of 0.
+func parseStreamID(s string) (StreamID, error) {
+	ms, seq, hasSeq := strings.Cut(s, "-")
+	var (
+		id  StreamID
+		err error
+	)
+	if id.Ms, err = strconv.ParseUint(ms, 10, 64); err != nil {
+		return StreamID{}, errInvalidStreamID
+	}
+	if hasSeq {
+		if id.Seq, err = strconv.ParseUint(seq, 10, 64); err != nil {
+			return StreamID{}, errInvalidStreamID
+		}
+	}
+	return id, nil
+}
+
+// nextID returns the ID of an entry added with the ID argument of XADD:
+// "*", "<ms>-*", "<ms>" or "<ms>-<seq>". The sequence is generated unless
+// given.
+//
+// t_stream.c::streamAppendItem
+func (s *Stream) nextID(arg string, nowMs int64) (StreamID, error) {
+	last := s.LastID
+	if arg == "*" {
+		if uint64(nowMs) > last.Ms {
+			return StreamID{Ms: uint64(nowMs)}, nil
+		}
+		arg = strconv.FormatUint(last.Ms, 10)
+	}
+
+	ms, seq, hasSeq := strings.Cut(arg, "-")
+	if !hasSeq || seq == "*" {
+		id, err := parseStreamID(ms)
+		if err != nil {
+			return StreamID{}, err
+		}
+		switch {
+		case id.Ms < last.Ms:
+			return StreamID{}, errStreamIDTooSmall
+		case id.Ms == last.Ms:
+			if last.Seq == ^uint64(0) {
+				if last.Ms == ^uint64(0) {
+					return StreamID{}, errStreamIDExhausted
+				}
+				return StreamID{}, errStreamIDTooSmall
+			}
+			id.Seq = last.Seq + 1
+		}
+		return id, nil
+	}
+
+	id, err := parseStreamID(arg)
+	if err != nil {
+		return StreamID{}, err
+	}
+	if id == (StreamID{}) {
+		return StreamID{}, errStreamIDZero
+	}
+	if !last.less(id) {
+		return StreamID{}, errStreamIDTooSmall
+	}
+	return id, nil
+}
+
+// streamTrim is the trimming of XADD and XTRIM. Redis propagates an
+// approximate trimming (~) as the exact trimming it did.
+type streamTrim struct {
+	maxLen bool
+	len    int64
+	minID  StreamID
+}
+
+// parseStreamTrim parses MAXLEN | MINID [= | ~] threshold [LIMIT count] at
+// args[i], it returns the index of the argument following it.
+//
+// t_stream.c::streamParseAddOrTrimArgsOrReply
+func parseStreamTrim(args []string, i int) (*streamTrim, int, error) {
+	t := &streamTrim{maxLen: strings.EqualFold(args[i], "MAXLEN")}
+	i++
+	if i < len(args) && (args[i] == "=" || args[i] == "~") {
+		i++
+	}
+	if i >= len(args) {
+		return nil, 0, errSyntax
+	}
+	if t.maxLen {
+		n, err := parseInt(args[i])
+		if err != nil {
+			return nil, 0, err
+		}
+		if n < 0 {
+			return nil, 0, errors.New("ERR The MAXLEN argument must be >= 0.")
+		}
+		t.len = n
+	} else {
+		id, err := parseStreamID(args[i])
+		if err != nil {
+			return nil, 0, err
+		}
+		t.minID = id
+	}
+	i++
+	if i < len(args) && strings.EqualFold(args[i], "LIMIT") {
+		if i+1 >= len(args) {
+			return nil, 0, errSyntax
+		}
+		if _, err := parseInt(args[i+1]); err != nil {
+			return nil, 0, err
+		}
+		i += 2
+	}
+	return t, i, nil
+}
+
+func (s *Stream) trim(t *streamTrim) {
+	n := 0
+	if t.maxLen {
+		if excess := int64(len(s.Entries)) - t.len; excess > 0 {
+			n = int(excess)
+		}
+	} else {
+		for n < len(s.Entries) && s.Entries[n].ID.less(t.minID) {
+			n++
+		}
+	}
+	s.Entries = s.Entries[n:]
+}
+
+// XADD key [NOMKSTREAM] [MAXLEN | MINID [= | ~] threshold [LIMIT count]]
+// <* | id> field value [field value ...]
+//
+// t_stream.c::xaddCommand
+func xaddCommand(ks *Keyspace, c *command) error {
+	var (
+		noMkStream bool
+		trim       *streamTrim
+		err        error
+	)
+	i := 2
+options:
+	for i < len(c.args) {
+		switch strings.ToUpper(c.args[i]) {
+		case "NOMKSTREAM":
+			noMkStream = true
+			i++
+		case "MAXLEN", "MINID":
+			if trim, i, err = parseStreamTrim(c.args, i); err != nil {
+				return err
+			}
+		default:
+			break options
+		}
+	}
+	if i >= len(c.args) {
+		return errSyntax
+	}
+	fields := c.args[i+1:]
+	if len(fields) == 0 || len(fields)%2 != 0 {
+		return errWrongArgs(c.name)
+	}
+
+	v, err := ks.lookup(c.db, c.args[1], TypeStream)
+	if err != nil {
+		return err
+	}
+	if v == nil && noMkStream {
+		return nil
+	}
+	stream := &Stream{}
+	if v != nil {
+		stream = v.Stream
+	}
+	id, err := stream.nextID(c.args[i], ks.Now().UnixMilli())
+	if err != nil {
+		return err
+	}
+	if v == nil {
+		if v, err = ks.lookupOrCreate(c.db, c.args[1], TypeStream); err != nil {
+			return err
+		}
+	}
+	v.Stream.Entries = append(v.Stream.Entries, StreamEntry{ID: id, Fields: append([]string(nil), fields...)})
+	v.Stream.LastID = id
+	if trim != nil {
+		v.Stream.trim(trim)
+	}
+	return nil
+}
+
+// XDEL key id [id ...]
+func xdelCommand(ks *Keyspace, c *command) error {
+	ids := make(map[StreamID]struct{}, len(c.args)-2)
+	for _, arg := range c.args[2:] {
+		id, err := parseStreamID(arg)
+		if err != nil {
+			return err
+		}
+		ids[id] = struct{}{}
+	}
+	v, err := ks.lookup(c.db, c.args[1], TypeStream)
+	if err != nil || v == nil {
+		return err
+	}
+	entries := v.Stream.Entries[:0]
+	for _, e := range v.Stream.Entries {
+		if _, ok := ids[e.ID]; !ok {
+			entries = append(entries, e)
+		}
+	}
+	v.Stream.Entries = entries
+	return nil
+}
+
+// XTRIM key MAXLEN | MINID [= | ~] threshold [LIMIT count]
+func xtrimCommand(ks *Keyspace, c *command) error {
+	if !strings.EqualFold(c.args[2], "MAXLEN") && !strings.EqualFold(c.args[2], "MINID") {
+		return errSyntax
+	}
+	trim, i, err := parseStreamTrim(c.args, 2)
+	if err != nil {
+		return err
+	}
+	if i != len(c.args) {
+		return errSyntax
+	}
+	v, err := ks.lookup(c.db, c.args[1], TypeStream)
+	if err != nil || v == nil {
+		return err
+	}
+	v.Stream.trim(trim)
+	return nil
+}
+
+// XSETID key last-id [ENTRIESADDED entries-added] [MAXDELETEDID max-deleted-id]
+func xsetidCommand(ks *Keyspace, c *command) error {
+	id, err := parseStreamID(c.args[2])
+	if err != nil {
+		return err
+	}
+	for i := 3; i < len(c.args); i += 2 {
+		if i+1 >= len(c.args) {
+			return errSyntax
+		}
+		switch strings.ToUpper(c.args[i]) {
+		case "ENTRIESADDED":
+			if _, err := parseInt(c.args[i+1]); err != nil {
+				return err
+			}
+		case "MAXDELETEDID":
+			if _, err := parseStreamID(c.args[i+1]); err != nil {
+				return err
+			}
+		default:
+			return errSyntax
+		}
+	}
+
+	v, err := ks.lookup(c.db, c.args[1], TypeStream)
+	if err != nil {
+		return err
+	}
+	if v == nil {
+		return errNoSuchKey
+	}
+	if n := len(v.Stream.Entries); n > 0 && id.less(v.Stream.Entries[n-1].ID) {
+		return errStreamSetIDSmall
+	}
+	v.Stream.LastID = id
+	return nil
+}
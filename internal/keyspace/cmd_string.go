@@ -0,0 +1,277 @@
+package keyspace
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+func (ks *Keyspace) setString(db int, key, value string, expireAt int64) {
+	ks.db(db)[key] = &Value{Type: TypeString, String: value, ExpireAt: expireAt}
+}
+
+// SET key value [NX | XX] [GET] [EX seconds | PX milliseconds |
+// EXAT unix-time-seconds | PXAT unix-time-milliseconds | KEEPTTL]
+//
+// t_string.c::parseExtendedStringArgumentsOrReply
+func setCommand(ks *Keyspace, c *command) error {
+	var (
+		nx, xx, keepTTL bool
+		expireAt        int64 = -1
+		hasExpire       bool
+	)
+	for i := 3; i < len(c.args); i++ {
+		opt := strings.ToUpper(c.args[i])
+		switch opt {
+		case "NX":
+			nx = true
+		case "XX":
+			xx = true
+		case "GET":
+		case "KEEPTTL":
+			keepTTL = true
+		case "EX", "PX", "EXAT", "PXAT":
+			if i+1 == len(c.args) || hasExpire {
+				return errSyntax
+			}
+			when, err := ks.expireTime(opt, c.args[i+1])
+			if err != nil {
+				return err
+			}
+			expireAt = when
+			hasExpire = true
+			i++
+		default:
+			return errSyntax
+		}
+	}
+	if (nx && xx) || (keepTTL && hasExpire) {
+		return errSyntax
+	}
+
+	old := ks.dbs[c.db][c.args[1]]
+	if (nx && old != nil) || (xx && old == nil) {
+		return nil
+	}
+	if keepTTL && old != nil {
+		expireAt = old.ExpireAt
+	}
+	ks.setString(c.db, c.args[1], c.args[2], expireAt)
+	return nil
+}
+
+func setnxCommand(ks *Keyspace, c *command) error {
+	if ks.dbs[c.db][c.args[1]] == nil {
+		ks.setString(c.db, c.args[1], c.args[2], -1)
+	}
+	return nil
+}
+
+// SETEX key seconds value, and PSETEX.
+func setexCommand(ks *Keyspace, c *command) error {
+	unit := "EX"
+	if c.name == "psetex" {
+		unit = "PX"
+	}
+	expireAt, err := ks.expireTime(unit, c.args[2])
+	if err != nil {
+		return err
+	}
+	ks.setString(c.db, c.args[1], c.args[3], expireAt)
+	return nil
+}
+
+// MSET key value [key value ...], and MSETNX.
+func msetCommand(ks *Keyspace, c *command) error {
+	if len(c.args)%2 != 1 {
+		return errWrongArgs(c.name)
+	}
+	if c.name == "msetnx" {
+		for i := 1; i < len(c.args); i += 2 {
+			if ks.dbs[c.db][c.args[i]] != nil {
+				return nil
+			}
+		}
+	}
+	for i := 1; i < len(c.args); i += 2 {
+		ks.setString(c.db, c.args[i], c.args[i+1], -1)
+	}
+	return nil
+}
+
+func getsetCommand(ks *Keyspace, c *command) error {
+	if _, err := ks.lookup(c.db, c.args[1], TypeString); err != nil {
+		return err
+	}
+	ks.setString(c.db, c.args[1], c.args[2], -1)
+	return nil
+}
+
+func getdelCommand(ks *Keyspace, c *command) error {
+	v, err := ks.lookup(c.db, c.args[1], TypeString)
+	if err != nil {
+		return err
+	}
+	if v != nil {
+		delete(ks.dbs[c.db], c.args[1])
+	}
+	return nil
+}
+
+// GETEX key [EX seconds | PX milliseconds | EXAT unix-time-seconds |
+// PXAT unix-time-milliseconds | PERSIST]
+func getexCommand(ks *Keyspace, c *command) error {
+	var (
+		expireAt  int64 = -1
+		hasExpire bool
+		persist   bool
+	)
+	for i := 2; i < len(c.args); i++ {
+		opt := strings.ToUpper(c.args[i])
+		switch opt {
+		case "PERSIST":
+			persist = true
+		case "EX", "PX", "EXAT", "PXAT":
+			if i+1 == len(c.args) || hasExpire {
+				return errSyntax
+			}
+			when, err := ks.expireTime(opt, c.args[i+1])
+			if err != nil {
+				return err
+			}
+			expireAt = when
+			hasExpire = true
+			i++
+		default:
+			return errSyntax
+		}
+	}
+	if persist && hasExpire {
+		return errSyntax
+	}
+
+	v, err := ks.lookup(c.db, c.args[1], TypeString)
+	if err != nil || v == nil {
+		return err
+	}
+	switch {
+	case persist:
+		v.ExpireAt = -1
+	case hasExpire:
+		v.ExpireAt = expireAt
+	}
+	return nil
+}
+
+func appendCommand(ks *Keyspace, c *command) error {
+	v, err := ks.lookup(c.db, c.args[1], TypeString)
+	if err != nil {
+		return err
+	}
+	if v == nil {
+		ks.setString(c.db, c.args[1], c.args[2], -1)
+		return nil
+	}
+	v.String += c.args[2]
+	return nil
+}
+
+// SETRANGE key offset value
+func setrangeCommand(ks *Keyspace, c *command) error {
+	offset, err := parseInt(c.args[2])
+	if err != nil {
+		return err
+	}
+	if offset < 0 || offset+int64(len(c.args[3])) > 512*1024*1024 {
+		return errNotInteger
+	}
+	v, err := ks.lookup(c.db, c.args[1], TypeString)
+	if err != nil {
+		return err
+	}
+	if len(c.args[3]) == 0 {
+		return nil
+	}
+	if v == nil {
+		v = &Value{Type: TypeString, ExpireAt: -1}
+		ks.db(c.db)[c.args[1]] = v
+	}
+
+	b := []byte(v.String)
+	if end := int(offset) + len(c.args[3]); end > len(b) {
+		b = append(b, make([]byte, end-len(b))...)
+	}
+	copy(b[offset:], c.args[3])
+	v.String = string(b)
+	return nil
+}
+
+// INCR key, and DECR, INCRBY, DECRBY.
+func incrCommand(ks *Keyspace, c *command) error {
+	var incr int64 = 1
+	if len(c.args) == 3 {
+		n, err := parseInt(c.args[2])
+		if err != nil {
+			return err
+		}
+		incr = n
+	}
+	if strings.HasPrefix(c.name, "decr") {
+		if incr == math.MinInt64 {
+			return errOverflow
+		}
+		incr = -incr
+	}
+
+	v, err := ks.lookup(c.db, c.args[1], TypeString)
+	if err != nil {
+		return err
+	}
+	var current int64
+	if v != nil {
+		if current, err = parseInt(v.String); err != nil {
+			return err
+		}
+	}
+	if (incr < 0 && current < 0 && incr < math.MinInt64-current) ||
+		(incr > 0 && current > 0 && incr > math.MaxInt64-current) {
+		return errOverflow
+	}
+
+	value := strconv.FormatInt(current+incr, 10)
+	if v == nil {
+		ks.setString(c.db, c.args[1], value, -1)
+	} else {
+		v.String = value
+	}
+	return nil
+}
+
+func incrbyfloatCommand(ks *Keyspace, c *command) error {
+	incr, err := parseFloat(c.args[2])
+	if err != nil {
+		return err
+	}
+	v, err := ks.lookup(c.db, c.args[1], TypeString)
+	if err != nil {
+		return err
+	}
+	var current float64
+	if v != nil {
+		if current, err = parseFloat(v.String); err != nil {
+			return err
+		}
+	}
+	result := current + incr
+	if math.IsInf(result, 0) {
+		return errNotFloat
+	}
+
+	value := formatFloat(result)
+	if v == nil {
+		ks.setString(c.db, c.args[1], value, -1)
+	} else {
+		v.String = value
+	}
+	return nil
+}
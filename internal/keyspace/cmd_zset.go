@@ -0,0 +1,213 @@
+package keyspace
+
+import (
+	"math"
+	"strings"
+)
+
+// ZADD key [NX | XX] [GT | LT] [CH] [INCR] score member [score member ...]
+//
+// t_zset.c::zaddGenericCommand
+func zaddCommand(ks *Keyspace, c *command) error {
+	var nx, xx, gt, lt, incr bool
+	i := 2
+flags:
+	for ; i < len(c.args); i++ {
+		switch strings.ToUpper(c.args[i]) {
+		case "NX":
+			nx = true
+		case "XX":
+			xx = true
+		case "GT":
+			gt = true
+		case "LT":
+			lt = true
+		case "CH":
+		case "INCR":
+			incr = true
+		default:
+			break flags
+		}
+	}
+	pairs := c.args[i:]
+	if len(pairs) == 0 || len(pairs)%2 != 0 {
+		return errSyntax
+	}
+	if (nx && xx) || (gt && lt) || (nx && (gt || lt)) || (incr && len(pairs) != 2) {
+		return errSyntax
+	}
+	scores := make([]float64, 0, len(pairs)/2)
+	for j := 0; j < len(pairs); j += 2 {
+		score, err := parseFloat(pairs[j])
+		if err != nil {
+			return err
+		}
+		scores = append(scores, score)
+	}
+
+	v, err := ks.lookup(c.db, c.args[1], TypeZSet)
+	if err != nil {
+		return err
+	}
+	if v == nil {
+		if xx {
+			return nil
+		}
+		if v, err = ks.lookupOrCreate(c.db, c.args[1], TypeZSet); err != nil {
+			return err
+		}
+	}
+
+	for j, score := range scores {
+		member := pairs[j*2+1]
+		current, exists := v.ZSet[member]
+		if (nx && exists) || (xx && !exists) {
+			continue
+		}
+		if incr && exists {
+			score += current
+			if math.IsNaN(score) {
+				ks.deleteIfEmpty(c.db, c.args[1], v)
+				return errNaN
+			}
+		}
+		if exists && ((gt && score <= current) || (lt && score >= current)) {
+			continue
+		}
+		v.ZSet[member] = score
+	}
+	ks.deleteIfEmpty(c.db, c.args[1], v)
+	return nil
+}
+
+// ZINCRBY key increment member
+func zincrbyCommand(ks *Keyspace, c *command) error {
+	incr, err := parseFloat(c.args[2])
+	if err != nil {
+		return err
+	}
+	v, err := ks.lookupOrCreate(c.db, c.args[1], TypeZSet)
+	if err != nil {
+		return err
+	}
+	score := v.ZSet[c.args[3]] + incr
+	if math.IsNaN(score) {
+		ks.deleteIfEmpty(c.db, c.args[1], v)
+		return errNaN
+	}
+	v.ZSet[c.args[3]] = score
+	return nil
+}
+
+func zremCommand(ks *Keyspace, c *command) error {
+	v, err := ks.lookup(c.db, c.args[1], TypeZSet)
+	if err != nil || v == nil {
+		return err
+	}
+	for _, m := range c.args[2:] {
+		delete(v.ZSet, m)
+	}
+	ks.deleteIfEmpty(c.db, c.args[1], v)
+	return nil
+}
+
+// ZREMRANGEBYRANK key start stop
+func zremrangebyrankCommand(ks *Keyspace, c *command) error {
+	start, err := parseInt(c.args[2])
+	if err != nil {
+		return err
+	}
+	stop, err := parseInt(c.args[3])
+	if err != nil {
+		return err
+	}
+	v, err := ks.lookup(c.db, c.args[1], TypeZSet)
+	if err != nil || v == nil {
+		return err
+	}
+
+	members := v.ZMembers()
+	from, to, ok := rangeIndexes(start, stop, len(members))
+	if !ok {
+		return nil
+	}
+	for _, m := range members[from : to+1] {
+		delete(v.ZSet, m.Member)
+	}
+	ks.deleteIfEmpty(c.db, c.args[1], v)
+	return nil
+}
+
+// ZREMRANGEBYSCORE key min max
+func zremrangebyscoreCommand(ks *Keyspace, c *command) error {
+	min, minEx, err := parseScoreBound(c.args[2])
+	if err != nil {
+		return err
+	}
+	max, maxEx, err := parseScoreBound(c.args[3])
+	if err != nil {
+		return err
+	}
+	v, err := ks.lookup(c.db, c.args[1], TypeZSet)
+	if err != nil || v == nil {
+		return err
+	}
+
+	for m, score := range v.ZSet {
+		if (score > min || (!minEx && score == min)) && (score < max || (!maxEx && score == max)) {
+			delete(v.ZSet, m)
+		}
+	}
+	ks.deleteIfEmpty(c.db, c.args[1], v)
+	return nil
+}
+
+// ZPOPMIN key [count], and ZPOPMAX.
+func zpopCommand(ks *Keyspace, c *command) error {
+	if len(c.args) > 3 {
+		return errSyntax
+	}
+	count := int64(1)
+	if len(c.args) == 3 {
+		n, err := parseInt(c.args[2])
+		if err != nil || n < 0 {
+			return errNotInteger
+		}
+		count = n
+	}
+	v, err := ks.lookup(c.db, c.args[1], TypeZSet)
+	if err != nil || v == nil {
+		return err
+	}
+
+	members := v.ZMembers()
+	if count > int64(len(members)) {
+		count = int64(len(members))
+	}
+	if c.name == "zpopmin" {
+		members = members[:count]
+	} else {
+		members = members[int64(len(members))-count:]
+	}
+	for _, m := range members {
+		delete(v.ZSet, m.Member)
+	}
+	ks.deleteIfEmpty(c.db, c.args[1], v)
+	return nil
+}
+
+// parseScoreBound parses the min or max of a score range, which is
+// exclusive when prefixed with "(".
+//
+// t_zset.c::zslParseRange
+func parseScoreBound(s string) (float64, bool, error) {
+	exclusive := strings.HasPrefix(s, "(")
+	if exclusive {
+		s = s[1:]
+	}
+	f, err := parseFloat(s)
+	if err != nil {
+		return 0, false, errNotFloatRange
+	}
+	return f, exclusive, nil
+}
@@ -0,0 +1,173 @@
+package keyspace
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+type command struct {
+	db   int
+	name string
+
+	// Arguments, starting with the command name.
+	args []string
+}
+
+type commandSpec struct {
+	proc func(ks *Keyspace, c *command) error
+
+	// Number of arguments including the name, -N means at least N.
+	arity int
+}
+
+// Write commands as Redis propagates them to the AOF and replicas.
+//
+// commands.def
+var commandTable map[string]commandSpec
+
+func init() {
+	commandTable = map[string]commandSpec{
+		// Markers of the propagated stream that do not change the key space.
+		"ping":    {noopCommand, -1},
+		"multi":   {noopCommand, 1},
+		"exec":    {noopCommand, 1},
+		"publish": {noopCommand, 3},
+
+		"del":       {delCommand, -2},
+		"unlink":    {delCommand, -2},
+		"expire":    {expireCommand, -3},
+		"pexpire":   {expireCommand, -3},
+		"expireat":  {expireCommand, -3},
+		"pexpireat": {expireCommand, -3},
+		"persist":   {persistCommand, 2},
+		"rename":    {renameCommand, 3},
+		"renamenx":  {renameCommand, 3},
+		"move":      {moveCommand, 3},
+		"copy":      {copyCommand, -3},
+		"flushdb":   {flushdbCommand, -1},
+		"flushall":  {flushallCommand, -1},
+		"swapdb":    {swapdbCommand, 3},
+
+		"set":         {setCommand, -3},
+		"setnx":       {setnxCommand, 3},
+		"setex":       {setexCommand, 4},
+		"psetex":      {setexCommand, 4},
+		"mset":        {msetCommand, -3},
+		"msetnx":      {msetCommand, -3},
+		"getset":      {getsetCommand, 3},
+		"getdel":      {getdelCommand, 2},
+		"getex":       {getexCommand, -2},
+		"append":      {appendCommand, 3},
+		"setrange":    {setrangeCommand, 4},
+		"incr":        {incrCommand, 2},
+		"decr":        {incrCommand, 2},
+		"incrby":      {incrCommand, 3},
+		"decrby":      {incrCommand, 3},
+		"incrbyfloat": {incrbyfloatCommand, 3},
+
+		"lpush":     {pushCommand, -3},
+		"rpush":     {pushCommand, -3},
+		"lpushx":    {pushCommand, -3},
+		"rpushx":    {pushCommand, -3},
+		"lpop":      {popCommand, -2},
+		"rpop":      {popCommand, -2},
+		"lset":      {lsetCommand, 4},
+		"ltrim":     {ltrimCommand, 4},
+		"lrem":      {lremCommand, 4},
+		"linsert":   {linsertCommand, 5},
+		"rpoplpush": {lmoveCommand, 3},
+		"lmove":     {lmoveCommand, 5},
+
+		"sadd":        {saddCommand, -3},
+		"srem":        {sremCommand, -3},
+		"smove":       {smoveCommand, 4},
+		"sinterstore": {setOpStoreCommand, -3},
+		"sunionstore": {setOpStoreCommand, -3},
+		"sdiffstore":  {setOpStoreCommand, -3},
+
+		"zadd":             {zaddCommand, -4},
+		"zincrby":          {zincrbyCommand, 4},
+		"zrem":             {zremCommand, -3},
+		"zremrangebyrank":  {zremrangebyrankCommand, 4},
+		"zremrangebyscore": {zremrangebyscoreCommand, 4},
+		"zpopmin":          {zpopCommand, -2},
+		"zpopmax":          {zpopCommand, -2},
+
+		"hset":         {hsetCommand, -4},
+		"hmset":        {hsetCommand, -4},
+		"hsetnx":       {hsetnxCommand, 4},
+		"hdel":         {hdelCommand, -3},
+		"hincrby":      {hincrbyCommand, 4},
+		"hincrbyfloat": {hincrbyfloatCommand, 4},
+
+		"xadd":   {xaddCommand, -5},
+		"xdel":   {xdelCommand, -3},
+		"xtrim":  {xtrimCommand, -4},
+		"xsetid": {xsetidCommand, -3},
+	}
+}
+
+var (
+	errSyntax          = errors.New("ERR syntax error")
+	errNotInteger      = errors.New("ERR value is not an integer or out of range")
+	errNotFloat        = errors.New("ERR value is not a valid float")
+	errNoSuchKey       = errors.New("ERR no such key")
+	errOverflow        = errors.New("ERR increment or decrement would overflow")
+	errIndexOutOfRange = errors.New("ERR index out of range")
+	errHashNotInteger  = errors.New("ERR hash value is not an integer")
+	errHashNotFloat    = errors.New("ERR hash value is not a float")
+	errNaN             = errors.New("ERR resulting score is not a number (NaN)")
+	errNotFloatRange   = errors.New("ERR min or max is not a float")
+)
+
+func errWrongArgs(name string) error {
+	return errors.New("ERR wrong number of arguments for '" + name + "' command")
+}
+
+func parseInt(s string) (int64, error) {
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, errNotInteger
+	}
+	return n, nil
+}
+
+func parseFloat(s string) (float64, error) {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(f) {
+		return 0, errNotFloat
+	}
+	return f, nil
+}
+
+// formatFloat formats the result of INCRBYFLOAT and HINCRBYFLOAT.
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func noopCommand(ks *Keyspace, c *command) error {
+	return nil
+}
+
+// expireTime converts the expire time argument of a command to milliseconds
+// since the epoch. unit is "EX", "PX", "EXAT" or "PXAT".
+func (ks *Keyspace) expireTime(unit, arg string) (int64, error) {
+	n, err := parseInt(arg)
+	if err != nil {
+		return 0, err
+	}
+	switch strings.ToUpper(unit) {
+	case "EX":
+		return ks.Now().UnixMilli() + n*1000, nil
+	case "PX":
+		return ks.Now().UnixMilli() + n, nil
+	case "EXAT":
+		return n * 1000, nil
+	case "PXAT":
+		return n, nil
+	default:
+		return 0, errSyntax
+	}
+}
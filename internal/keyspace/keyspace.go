@@ -0,0 +1,268 @@
+// Package keyspace is an in-memory model of the Redis key space, built by
+// applying the write commands found in AOF files and replication streams.
+package keyspace
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+type Type uint8
+
+const (
+	TypeString Type = iota
+	TypeList
+	TypeSet
+	TypeZSet
+	TypeHash
+	TypeStream
+)
+
+func (t Type) String() string {
+	switch t {
+	case TypeString:
+		return "string"
+	case TypeList:
+		return "list"
+	case TypeSet:
+		return "set"
+	case TypeZSet:
+		return "zset"
+	case TypeHash:
+		return "hash"
+	case TypeStream:
+		return "stream"
+	default:
+		return "unknown"
+	}
+}
+
+// Value is the value of a key, only the field of its type is set.
+type Value struct {
+	Type Type
+
+	String string
+	List   []string
+	Set    map[string]struct{}
+	ZSet   map[string]float64
+	Hash   map[string]string
+	Stream *Stream
+
+	// Milliseconds since the epoch, -1 if the key does not expire.
+	ExpireAt int64
+}
+
+// ZMember is a member of a sorted set.
+type ZMember struct {
+	Member string
+	Score  float64
+}
+
+// ZMembers returns the members of a sorted set ordered by score, then member.
+func (v *Value) ZMembers() []ZMember {
+	members := make([]ZMember, 0, len(v.ZSet))
+	for m, score := range v.ZSet {
+		members = append(members, ZMember{Member: m, Score: score})
+	}
+	sort.Slice(members, func(i, j int) bool {
+		if members[i].Score != members[j].Score {
+			return members[i].Score < members[j].Score
+		}
+		return members[i].Member < members[j].Member
+	})
+	return members
+}
+
+func (v *Value) clone() *Value {
+	c := &Value{
+		Type:     v.Type,
+		String:   v.String,
+		ExpireAt: v.ExpireAt,
+	}
+	if v.List != nil {
+		c.List = append([]string(nil), v.List...)
+	}
+	if v.Set != nil {
+		c.Set = make(map[string]struct{}, len(v.Set))
+		for m := range v.Set {
+			c.Set[m] = struct{}{}
+		}
+	}
+	if v.ZSet != nil {
+		c.ZSet = make(map[string]float64, len(v.ZSet))
+		for m, score := range v.ZSet {
+			c.ZSet[m] = score
+		}
+	}
+	if v.Hash != nil {
+		c.Hash = make(map[string]string, len(v.Hash))
+		for f, value := range v.Hash {
+			c.Hash[f] = value
+		}
+	}
+	if v.Stream != nil {
+		c.Stream = &Stream{
+			Entries: make([]StreamEntry, len(v.Stream.Entries)),
+			LastID:  v.Stream.LastID,
+		}
+		for i, e := range v.Stream.Entries {
+			c.Stream.Entries[i] = StreamEntry{ID: e.ID, Fields: append([]string(nil), e.Fields...)}
+		}
+	}
+	return c
+}
+
+func (v *Value) empty() bool {
+	switch v.Type {
+	case TypeList:
+		return len(v.List) == 0
+	case TypeSet:
+		return len(v.Set) == 0
+	case TypeZSet:
+		return len(v.ZSet) == 0
+	case TypeHash:
+		return len(v.Hash) == 0
+	default:
+		return false
+	}
+}
+
+var ErrWrongType = errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+
+// UnsupportedCommandError is returned by Apply for commands the model does
+// not implement. The key space may no longer match the one of Redis.
+type UnsupportedCommandError struct {
+	Name string
+}
+
+func (e *UnsupportedCommandError) Error() string {
+	return "unsupported command '" + e.Name + "'"
+}
+
+// Keyspace holds the keys of all databases. Keys are not expired by the
+// model, as a replica Redis waits for the DEL of its master.
+type Keyspace struct {
+	dbs map[int]map[string]*Value
+
+	// Now is the time relative expire times, as in EXPIRE, are based on.
+	Now func() time.Time
+}
+
+func New() *Keyspace {
+	return &Keyspace{
+		dbs: make(map[int]map[string]*Value),
+		Now: time.Now,
+	}
+}
+
+// Apply executes a write command in database db.
+func (ks *Keyspace) Apply(db int, args [][]byte) error {
+	if len(args) == 0 {
+		return errors.New("empty command")
+	}
+	c := &command{
+		db:   db,
+		name: strings.ToLower(string(args[0])),
+		args: make([]string, len(args)),
+	}
+	for i, arg := range args {
+		c.args[i] = string(arg)
+	}
+
+	spec, ok := commandTable[c.name]
+	if !ok {
+		return &UnsupportedCommandError{Name: c.name}
+	}
+	if (spec.arity > 0 && len(c.args) != spec.arity) || len(c.args) < -spec.arity {
+		return errWrongArgs(c.name)
+	}
+	return spec.proc(ks, c)
+}
+
+// Get returns the value of key, or nil if it does not exist. The value must
+// not be modified.
+func (ks *Keyspace) Get(db int, key string) *Value {
+	return ks.dbs[db][key]
+}
+
+// Set sets the value of key, e.g. loaded from an RDB.
+func (ks *Keyspace) Set(db int, key string, v *Value) {
+	ks.db(db)[key] = v
+}
+
+// Dbs returns the databases holding keys, in ascending order.
+func (ks *Keyspace) Dbs() []int {
+	var dbs []int
+	for db, keys := range ks.dbs {
+		if len(keys) > 0 {
+			dbs = append(dbs, db)
+		}
+	}
+	sort.Ints(dbs)
+	return dbs
+}
+
+// Keys returns the keys of database db in lexicographical order.
+func (ks *Keyspace) Keys(db int) []string {
+	keys := make([]string, 0, len(ks.dbs[db]))
+	for key := range ks.dbs[db] {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Len returns the number of keys in database db.
+func (ks *Keyspace) Len(db int) int {
+	return len(ks.dbs[db])
+}
+
+func (ks *Keyspace) db(db int) map[string]*Value {
+	keys, ok := ks.dbs[db]
+	if !ok {
+		keys = make(map[string]*Value)
+		ks.dbs[db] = keys
+	}
+	return keys
+}
+
+// lookup returns the value of key, nil if it does not exist or ErrWrongType
+// if it is not of type t.
+func (ks *Keyspace) lookup(db int, key string, t Type) (*Value, error) {
+	v := ks.dbs[db][key]
+	if v != nil && v.Type != t {
+		return nil, ErrWrongType
+	}
+	return v, nil
+}
+
+// lookupOrCreate returns the value of key, creating an empty one of type t
+// if it does not exist.
+func (ks *Keyspace) lookupOrCreate(db int, key string, t Type) (*Value, error) {
+	v, err := ks.lookup(db, key, t)
+	if err != nil || v != nil {
+		return v, err
+	}
+	v = &Value{Type: t, ExpireAt: -1}
+	switch t {
+	case TypeSet:
+		v.Set = make(map[string]struct{})
+	case TypeZSet:
+		v.ZSet = make(map[string]float64)
+	case TypeHash:
+		v.Hash = make(map[string]string)
+	case TypeStream:
+		v.Stream = &Stream{}
+	}
+	ks.db(db)[key] = v
+	return v, nil
+}
+
+// deleteIfEmpty removes aggregates left without elements, as Redis does.
+func (ks *Keyspace) deleteIfEmpty(db int, key string, v *Value) {
+	if v != nil && v.empty() {
+		delete(ks.dbs[db], key)
+	}
+}
@@ -0,0 +1,258 @@
+package keyspace
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestKeyspace() *Keyspace {
+	ks := New()
+	ks.Now = func() time.Time {
+		return time.UnixMilli(1700000000000)
+	}
+	return ks
+}
+
+func apply(t *testing.T, ks *Keyspace, db int, commands ...string) {
+	t.Helper()
+	for _, command := range commands {
+		var args [][]byte
+		for _, arg := range strings.Fields(command) {
+			args = append(args, []byte(arg))
+		}
+		if err := ks.Apply(db, args); err != nil {
+			t.Fatalf("%s: %v", command, err)
+		}
+	}
+}
+
+func setMembers(v *Value) []string {
+	var members []string
+	for m := range v.Set {
+		members = append(members, m)
+	}
+	sort.Strings(members)
+	return members
+}
+
+func TestKeyspace_String(t *testing.T) {
+	ks := newTestKeyspace()
+	apply(t, ks, 0,
+		"SET a 1",
+		"INCRBY a 9",
+		"APPEND a x",
+		"SET b v EX 10",
+		"SET b w KEEPTTL",
+		"SET b z NX",
+		"SET c v XX",
+		"SETEX d 5 v",
+		"MSET e 1 f 2",
+		"MSETNX f 3 g 4",
+		"SETRANGE h 2 ab",
+		"INCRBYFLOAT i 1.5",
+		"GETDEL e",
+	)
+
+	assert.Equal(t, "10x", ks.Get(0, "a").String)
+	assert.Equal(t, int64(-1), ks.Get(0, "a").ExpireAt)
+	assert.Equal(t, "w", ks.Get(0, "b").String)
+	assert.Equal(t, int64(1700000010000), ks.Get(0, "b").ExpireAt)
+	assert.Nil(t, ks.Get(0, "c"))
+	assert.Equal(t, int64(1700000005000), ks.Get(0, "d").ExpireAt)
+	assert.Equal(t, "2", ks.Get(0, "f").String)
+	assert.Nil(t, ks.Get(0, "g"))
+	assert.Equal(t, "\x00\x00ab", ks.Get(0, "h").String)
+	assert.Equal(t, "1.5", ks.Get(0, "i").String)
+	assert.Equal(t, []string{"a", "b", "d", "f", "h", "i"}, ks.Keys(0))
+}
+
+func TestKeyspace_Generic(t *testing.T) {
+	ks := newTestKeyspace()
+	apply(t, ks, 0,
+		"SET a 1",
+		"EXPIRE a 100",
+		"EXPIRE a 10 GT",
+		"PEXPIREAT a 1800000000000 XX",
+		"RENAME a b",
+		"SET c 1",
+		"RENAMENX c b",
+		"MOVE c 1",
+		"COPY b d DB 2",
+	)
+	assert.Equal(t, []string{"b"}, ks.Keys(0))
+	assert.Equal(t, int64(1800000000000), ks.Get(0, "b").ExpireAt)
+	assert.Equal(t, []string{"c"}, ks.Keys(1))
+	assert.Equal(t, int64(1800000000000), ks.Get(2, "d").ExpireAt)
+	assert.Equal(t, []int{0, 1, 2}, ks.Dbs())
+
+	apply(t, ks, 0, "PERSIST b", "SWAPDB 0 3")
+	assert.Equal(t, int64(-1), ks.Get(3, "b").ExpireAt)
+	assert.Equal(t, []int{1, 2, 3}, ks.Dbs())
+
+	apply(t, ks, 1, "FLUSHDB")
+	assert.Equal(t, []int{2, 3}, ks.Dbs())
+	apply(t, ks, 2, "DEL d x", "FLUSHALL")
+	assert.Empty(t, ks.Dbs())
+}
+
+func TestKeyspace_List(t *testing.T) {
+	ks := newTestKeyspace()
+	apply(t, ks, 0,
+		"RPUSH l a b c",
+		"LPUSH l y z",
+		"LINSERT l AFTER a x",
+		"LSET l -1 C",
+		"RPUSH l a a",
+		"LREM l -1 a",
+		"LPOP l",
+		"RPOPLPUSH l m",
+		"LMOVE m m LEFT RIGHT",
+		"LPUSHX n a",
+	)
+	assert.Equal(t, []string{"y", "a", "x", "b", "C"}, ks.Get(0, "l").List)
+	assert.Equal(t, []string{"a"}, ks.Get(0, "m").List)
+	assert.Nil(t, ks.Get(0, "n"))
+
+	apply(t, ks, 0, "LTRIM l 1 -2", "RPOP m 5")
+	assert.Equal(t, []string{"a", "x", "b"}, ks.Get(0, "l").List)
+	assert.Nil(t, ks.Get(0, "m"))
+}
+
+func TestKeyspace_Set(t *testing.T) {
+	ks := newTestKeyspace()
+	apply(t, ks, 0,
+		"SADD s1 a b c",
+		"SADD s2 b c d",
+		"SREM s1 a",
+		"SMOVE s2 s3 d",
+		"SINTERSTORE i s1 s2",
+		"SUNIONSTORE u s1 s3",
+		"SDIFFSTORE d s1 s2",
+	)
+	assert.Equal(t, []string{"b", "c"}, setMembers(ks.Get(0, "i")))
+	assert.Equal(t, []string{"b", "c", "d"}, setMembers(ks.Get(0, "u")))
+	assert.Nil(t, ks.Get(0, "d"))
+}
+
+func TestKeyspace_ZSet(t *testing.T) {
+	ks := newTestKeyspace()
+	apply(t, ks, 0,
+		"ZADD z 1 a 2 b 3 c 4 d",
+		"ZADD z NX 9 a 5 e",
+		"ZADD z GT 0 b 6 c",
+		"ZADD z XX INCR 1 a",
+		"ZINCRBY z -10 d",
+		"ZREM z e",
+		"ZADD y XX 1 a",
+	)
+	assert.Equal(t, []ZMember{{"d", -6}, {"a", 2}, {"b", 2}, {"c", 6}}, ks.Get(0, "z").ZMembers())
+	assert.Nil(t, ks.Get(0, "y"))
+
+	apply(t, ks, 0, "ZREMRANGEBYSCORE z (2 +inf", "ZPOPMIN z", "ZADD z 0 e")
+	assert.Equal(t, []ZMember{{"e", 0}, {"a", 2}, {"b", 2}}, ks.Get(0, "z").ZMembers())
+	apply(t, ks, 0, "ZREMRANGEBYRANK z 0 -1")
+	assert.Nil(t, ks.Get(0, "z"))
+}
+
+func TestKeyspace_Hash(t *testing.T) {
+	ks := newTestKeyspace()
+	apply(t, ks, 0,
+		"HSET h a 1 b 2",
+		"HSETNX h a 9",
+		"HINCRBY h a 5",
+		"HINCRBYFLOAT h c 0.5",
+		"HDEL h b",
+	)
+	assert.Equal(t, map[string]string{"a": "6", "c": "0.5"}, ks.Get(0, "h").Hash)
+	apply(t, ks, 0, "HDEL h a c")
+	assert.Nil(t, ks.Get(0, "h"))
+}
+
+func TestKeyspace_Stream(t *testing.T) {
+	ks := newTestKeyspace()
+	apply(t, ks, 0,
+		"XADD s 1-1 a 1",
+		"XADD s 1-* b 2",
+		"XADD s 2 c 3",
+		"XADD s MAXLEN = 2 3-0 d 4",
+		"XADD s NOMKSTREAM MINID ~ 3 LIMIT 100 * e 5",
+		"XADD missing NOMKSTREAM * a 1",
+		"XDEL s 3-0",
+	)
+	v := ks.Get(0, "s")
+	assert.Equal(t, []StreamEntry{
+		{ID: StreamID{Ms: 1700000000000}, Fields: []string{"e", "5"}},
+	}, v.Stream.Entries)
+	assert.Nil(t, ks.Get(0, "missing"))
+
+	apply(t, ks, 0, "XSETID s 1700000000001-5", "XTRIM s MAXLEN 0", "XADD s 1700000000001-* f 6")
+	v = ks.Get(0, "s")
+	assert.Equal(t, []StreamEntry{
+		{ID: StreamID{Ms: 1700000000001, Seq: 6}, Fields: []string{"f", "6"}},
+	}, v.Stream.Entries)
+
+	// The stream is kept when empty.
+	apply(t, ks, 0, "XTRIM s MINID 1700000000002")
+	assert.Empty(t, ks.Get(0, "s").Stream.Entries)
+
+	for _, command := range []string{
+		"XADD s 1-1 a 1",
+		"XADD s 0-0 a 1",
+		"XADD s * a",
+		"XSETID s 1-1 ENTRIESADDED",
+		"XTRIM s LEN 1",
+	} {
+		var args [][]byte
+		for _, arg := range strings.Fields(command) {
+			args = append(args, []byte(arg))
+		}
+		assert.Error(t, ks.Apply(0, args), command)
+	}
+}
+
+func TestKeyspace_Errors(t *testing.T) {
+	ks := newTestKeyspace()
+	apply(t, ks, 0, "SET s v", "RPUSH l a")
+
+	err := ks.Apply(0, [][]byte{[]byte("EVAL"), []byte("return 1"), []byte("0")})
+	var unsupported *UnsupportedCommandError
+	if assert.True(t, errors.As(err, &unsupported)) {
+		assert.Equal(t, "eval", unsupported.Name)
+	}
+
+	for _, command := range []string{
+		"SADD s a",
+		"INCR l",
+		"INCR s",
+		"RENAME missing x",
+		"SET a",
+		"SET a b EX 1 PX 1",
+		"ZADD z NX XX 1 a",
+	} {
+		var args [][]byte
+		for _, arg := range strings.Fields(command) {
+			args = append(args, []byte(arg))
+		}
+		assert.Error(t, ks.Apply(0, args), command)
+	}
+	assert.Equal(t, []string{"l", "s"}, ks.Keys(0))
+}
+
+func TestReport(t *testing.T) {
+	r := NewReport()
+	for i := 0; i < maxReportedFailures+1; i++ {
+		r.Fail(&Failure{Offset: int64(i), Name: "EVAL", Err: errors.New("unsupported")})
+	}
+	c := r.Clone()
+	r.Fail(&Failure{Name: "XGROUP", Err: errors.New("unsupported")})
+
+	assert.Equal(t, maxReportedFailures+1, c.Failed)
+	assert.Len(t, c.Failures, maxReportedFailures)
+	assert.Equal(t, map[string]int{"EVAL": maxReportedFailures + 1}, c.FailedByName)
+	assert.Equal(t, "offset 1, db 0: unsupported", c.Failures[1].Error())
+	assert.Equal(t, 2, len(r.FailedByName))
+}
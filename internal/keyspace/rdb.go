@@ -0,0 +1,60 @@
+package keyspace
+
+import (
+	"github.com/vczyh/redis-lib/rdb"
+)
+
+// RdbValue converts a key of an RDB to its value, it returns nil for the
+// events that are not keys.
+func RdbValue(e rdb.Event) (rdb.RedisKey, *Value) {
+	var (
+		key rdb.RedisKey
+		v   *Value
+	)
+	switch o := e.(type) {
+	case *rdb.StringObjectEvent:
+		key = o.RedisKey
+		v = &Value{Type: TypeString, String: o.Value}
+	case *rdb.ListObjectEvent:
+		key = o.RedisKey
+		v = &Value{Type: TypeList, List: o.Elements}
+	case *rdb.SetObjectEvent:
+		key = o.RedisKey
+		v = &Value{Type: TypeSet, Set: make(map[string]struct{}, len(o.Members))}
+		for _, m := range o.Members {
+			v.Set[m] = struct{}{}
+		}
+	case *rdb.ZSetObjectEvent:
+		key = o.RedisKey
+		v = &Value{Type: TypeZSet, ZSet: make(map[string]float64, len(o.Members))}
+		for _, m := range o.Members {
+			v.ZSet[m.Value] = m.Score
+		}
+	case *rdb.HashObjectEvent:
+		key = o.RedisKey
+		v = &Value{Type: TypeHash, Hash: make(map[string]string, len(o.Fields))}
+		for _, f := range o.Fields {
+			v.Hash[f.Field] = f.Value
+		}
+	case *rdb.StreamObjectEvent:
+		key = o.RedisKey
+		v = &Value{Type: TypeStream, Stream: &Stream{
+			Entries: make([]StreamEntry, 0, len(o.Entries)),
+			LastID:  StreamID{Ms: o.LastId.Ms, Seq: o.LastId.Seq},
+		}}
+		for _, e := range o.Entries {
+			fields := make([]string, 0, 2*len(e.Fields))
+			for _, f := range e.Fields {
+				fields = append(fields, f.Field, f.Value)
+			}
+			v.Stream.Entries = append(v.Stream.Entries, StreamEntry{
+				ID:     StreamID{Ms: e.Id.Ms, Seq: e.Id.Seq},
+				Fields: fields,
+			})
+		}
+	default:
+		return rdb.RedisKey{}, nil
+	}
+	v.ExpireAt = key.ExpireAt()
+	return key, v
+}
@@ -0,0 +1,72 @@
+package keyspace
+
+import (
+	"fmt"
+)
+
+// Failures kept in Report.Failures, the others are only counted.
+const maxReportedFailures = 100
+
+// Report tells how the commands and RDB keys were applied to a Keyspace.
+// If Failed is not zero, the Keyspace does not match the data set of Redis.
+type Report struct {
+	// Number of commands and RDB keys applied.
+	Applied int
+
+	// Number of commands and RDB keys that could not be applied, the first
+	// of them are in Failures.
+	Failed   int
+	Failures []*Failure
+
+	// Number of failures by command name, e.g. "EVAL", or RDB value type,
+	// e.g. "rdb stream".
+	FailedByName map[string]int
+}
+
+func NewReport() Report {
+	return Report{FailedByName: make(map[string]int)}
+}
+
+// Fail counts a failure, the first ones are kept in Failures.
+func (r *Report) Fail(f *Failure) {
+	r.Failed++
+	r.FailedByName[f.Name]++
+	if len(r.Failures) < maxReportedFailures {
+		r.Failures = append(r.Failures, f)
+	}
+}
+
+// Clone returns a copy of the report that the next failures do not change.
+func (r *Report) Clone() Report {
+	c := *r
+	c.Failures = append([]*Failure(nil), r.Failures...)
+	c.FailedByName = make(map[string]int, len(r.FailedByName))
+	for name, n := range r.FailedByName {
+		c.FailedByName[name] = n
+	}
+	return c
+}
+
+// Failure is a command, or RDB key, that could not be applied.
+type Failure struct {
+	// File of a multi part AOF the command is read from, empty otherwise.
+	File string
+
+	// Offset of the command in the file or the replication stream, and the
+	// database it applies to.
+	Offset int64
+	Db     int
+
+	// Arguments of the command, nil for an RDB key.
+	Args [][]byte
+
+	Name string
+	Err  error
+}
+
+func (f *Failure) Error() string {
+	if f.File != "" {
+		return fmt.Sprintf("%s: offset %d, db %d: %s", f.File, f.Offset, f.Db, f.Err)
+	}
+	return fmt.Sprintf("offset %d, db %d: %s", f.Offset, f.Db, f.Err)
+}
@@ -0,0 +1,268 @@
+// Package mirror keeps an in-memory copy of the data set of a Redis master,
+// loaded from the RDB of a full synchronization and kept up to date with the
+// replicated write commands, to be read locally like a replica.
+package mirror
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vczyh/redis-lib/internal/keyspace"
+	"github.com/vczyh/redis-lib/replica"
+)
+
+const (
+	// Keys returned by Scan without a count, as SCAN.
+	defaultScanCount = 10
+)
+
+// ErrWrongType is returned by the reads of a key holding another type.
+var ErrWrongType = keyspace.ErrWrongType
+
+// Report tells how the events were applied, Applied counts the keys loaded
+// from RDB and the commands. If Failed is not zero, the mirror may no
+// longer match the master.
+type Report = keyspace.Report
+
+// Failure is a replicated command the mirror could not apply, e.g. a
+// command the mirror does not implement. Offset is the StartOffset of the
+// command.
+type Failure = keyspace.Failure
+
+// ZMember is a member of a sorted set.
+type ZMember struct {
+	Member string
+	Score  float64
+}
+
+// Mirror is the data set of a master. The reads are safe to call while the
+// events are applied.
+//
+// Like a Redis replica, the mirror does not expire keys, it waits for the
+// DEL of the master. The reads hide the keys already expired however.
+type Mirror struct {
+	mu     sync.RWMutex
+	ks     *keyspace.Keyspace
+	report Report
+
+	now            func() time.Time
+	failureHandler func(f *Failure)
+}
+
+type Option func(m *Mirror)
+
+// WithFailureHandler calls h for every command that can not be applied, in
+// addition to counting it in the report.
+func WithFailureHandler(h func(f *Failure)) Option {
+	return func(m *Mirror) {
+		m.failureHandler = h
+	}
+}
+
+// WithClock sets the time the expired keys are hidden at, and the relative
+// expire times of the commands are based on. Default time.Now.
+func WithClock(now func() time.Time) Option {
+	return func(m *Mirror) {
+		m.now = now
+	}
+}
+
+func New(opts ...Option) *Mirror {
+	m := &Mirror{
+		now:    time.Now,
+		report: keyspace.NewReport(),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	m.ks = m.newKeyspace()
+	return m
+}
+
+func (m *Mirror) newKeyspace() *keyspace.Keyspace {
+	ks := keyspace.New()
+	ks.Now = m.now
+	return ks
+}
+
+// Sync synchronizes with the master of config and applies what is received,
+// until ctx is done or the synchronization fails. config is used as by
+// replica.NewStream.
+func (m *Mirror) Sync(ctx context.Context, config *replica.Config) error {
+	s, err := replica.NewStream(ctx, config)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	for s.HasNext() {
+		m.Apply(s.Next())
+	}
+	return s.Err()
+}
+
+// Apply applies an event of replica.Stream. A full synchronization replaces
+// the data set.
+func (m *Mirror) Apply(e *replica.StreamEvent) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	switch e.Type {
+	case replica.StreamEventTypeFullResync:
+		m.ks = m.newKeyspace()
+	case replica.StreamEventTypeRdb:
+		key, v := keyspace.RdbValue(e.Rdb.Event)
+		if v == nil {
+			return
+		}
+		m.ks.Set(key.DbId, key.Key, v)
+		m.report.Applied++
+	case replica.StreamEventTypeCommand:
+		cmd := e.Command
+		if err := m.ks.Apply(cmd.Db, cmd.Args); err != nil {
+			m.fail(&Failure{Offset: cmd.StartOffset, Db: cmd.Db, Args: cmd.Args, Name: commandName(cmd), Err: err})
+			return
+		}
+		m.report.Applied++
+	}
+}
+
+func (m *Mirror) fail(f *Failure) {
+	m.report.Fail(f)
+	if m.failureHandler != nil {
+		m.failureHandler(f)
+	}
+}
+
+// Report returns how the events applied so far went.
+func (m *Mirror) Report() Report {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.report.Clone()
+}
+
+// lookup returns the value of key if it is not expired, or ErrWrongType if
+// it is not of type t.
+func (m *Mirror) lookup(db int, key string, t keyspace.Type) (*keyspace.Value, error) {
+	v := m.ks.Get(db, key)
+	if v == nil || m.expired(v) {
+		return nil, nil
+	}
+	if v.Type != t {
+		return nil, ErrWrongType
+	}
+	return v, nil
+}
+
+func (m *Mirror) expired(v *keyspace.Value) bool {
+	return v.ExpireAt != -1 && v.ExpireAt <= m.now().UnixMilli()
+}
+
+// Get returns the value of a string key, ok is false if the key does not
+// exist.
+func (m *Mirror) Get(db int, key string) (value string, ok bool, err error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	v, err := m.lookup(db, key, keyspace.TypeString)
+	if err != nil || v == nil {
+		return "", false, err
+	}
+	return v.String, true, nil
+}
+
+// HGetAll returns the fields of a hash key, empty if the key does not exist.
+func (m *Mirror) HGetAll(db int, key string) (map[string]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	v, err := m.lookup(db, key, keyspace.TypeHash)
+	if err != nil {
+		return nil, err
+	}
+	fields := make(map[string]string)
+	if v != nil {
+		for f, value := range v.Hash {
+			fields[f] = value
+		}
+	}
+	return fields, nil
+}
+
+// ZRange returns the members of a sorted set key from rank start to stop
+// included, ordered by score, as ZRANGE. Negative ranks count from the end.
+func (m *Mirror) ZRange(db int, key string, start, stop int) ([]ZMember, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	v, err := m.lookup(db, key, keyspace.TypeZSet)
+	if err != nil || v == nil {
+		return nil, err
+	}
+	members := v.ZMembers()
+	n := len(members)
+	if start < 0 {
+		start += n
+	}
+	if stop < 0 {
+		stop += n
+	}
+	if start < 0 {
+		start = 0
+	}
+	if stop >= n {
+		stop = n - 1
+	}
+	if start > stop {
+		return nil, nil
+	}
+	result := make([]ZMember, 0, stop-start+1)
+	for _, member := range members[start : stop+1] {
+		result = append(result, ZMember{Member: member.Member, Score: member.Score})
+	}
+	return result, nil
+}
+
+// Scan returns up to count keys, 10 if not positive, of database db matching
+// the glob-style pattern, all keys if it is empty, in lexicographical order
+// after cursor. Start with an empty cursor and pass the next cursor returned,
+// the scan is complete when it is empty. Keys existing during the whole scan
+// are returned exactly once.
+func (m *Mirror) Scan(db int, cursor, pattern string, count int) (keys []string, next string) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if count <= 0 {
+		count = defaultScanCount
+	}
+	all := m.ks.Keys(db)
+	i := sort.SearchStrings(all, cursor)
+	if i < len(all) && all[i] == cursor && cursor != "" {
+		i++
+	}
+	for ; i < len(all); i++ {
+		if len(keys) == count {
+			return keys, keys[len(keys)-1]
+		}
+		key := all[i]
+		if m.expired(m.ks.Get(db, key)) {
+			continue
+		}
+		if pattern != "" && !match(pattern, key) {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	return keys, ""
+}
+
+func commandName(cmd *replica.ReplicationCommand) string {
+	if len(cmd.Args) == 0 {
+		return ""
+	}
+	return strings.ToUpper(string(cmd.Args[0]))
+}
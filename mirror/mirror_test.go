@@ -0,0 +1,173 @@
+package mirror
+
+import (
+	"bytes"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/vczyh/redis-lib/internal/keyspace"
+	"github.com/vczyh/redis-lib/rdb"
+	"github.com/vczyh/redis-lib/replica"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testNow = time.UnixMilli(1700000000000)
+
+// newTestMirror returns a mirror loaded from an RDB written by write.
+func newTestMirror(t *testing.T, write func(w *rdb.Writer) error, opts ...Option) *Mirror {
+	t.Helper()
+	var buf bytes.Buffer
+	w := rdb.NewWriter(&buf)
+	if err := w.WriteHeader(); err != nil {
+		t.Fatal(err)
+	}
+	if err := write(w); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	m := New(append([]Option{WithClock(func() time.Time { return testNow })}, opts...)...)
+	m.Apply(&replica.StreamEvent{Type: replica.StreamEventTypeFullResync})
+	p, err := rdb.NewReaderParser(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, _ := p.Parse()
+	for s.HasNext() {
+		m.Apply(&replica.StreamEvent{Type: replica.StreamEventTypeRdb, Rdb: s.Next()})
+	}
+	if err := s.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func apply(m *Mirror, db int, commands ...string) {
+	for _, command := range commands {
+		var args [][]byte
+		for _, arg := range strings.Fields(command) {
+			args = append(args, []byte(arg))
+		}
+		m.Apply(&replica.StreamEvent{
+			Type:    replica.StreamEventTypeCommand,
+			Command: &replica.ReplicationCommand{Db: db, Args: args},
+		})
+	}
+}
+
+func TestMirror(t *testing.T) {
+	m := newTestMirror(t, func(w *rdb.Writer) error {
+		if err := w.SelectDb(0); err != nil {
+			return err
+		}
+		if err := w.WriteString("a", "1", -1); err != nil {
+			return err
+		}
+		if err := w.WriteString("expired", "1", testNow.UnixMilli()-1); err != nil {
+			return err
+		}
+		if err := w.WriteHash("h", []rdb.HashField{{Field: "f", Value: "v"}}, -1); err != nil {
+			return err
+		}
+		return w.WriteZSet("z", []rdb.ZSetMember{{Value: "x", Score: 1}, {Value: "y", Score: 2}}, -1)
+	})
+	apply(m, 0,
+		"SET b 2",
+		"HSET h g w",
+		"ZADD z 0 w",
+		"RENAME a c",
+		"XADD s 1-1 f v",
+	)
+	apply(m, 1, "SET a 3", "FLUSHDB")
+
+	_, ok, err := m.Get(0, "a")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	value, ok, err := m.Get(0, "c")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "1", value)
+	_, ok, _ = m.Get(0, "expired")
+	assert.False(t, ok)
+	_, _, err = m.Get(0, "h")
+	assert.True(t, errors.Is(err, ErrWrongType))
+
+	fields, err := m.HGetAll(0, "h")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"f": "v", "g": "w"}, fields)
+	fields, err = m.HGetAll(0, "missing")
+	assert.NoError(t, err)
+	assert.Empty(t, fields)
+
+	members, err := m.ZRange(0, "z", 1, -1)
+	assert.NoError(t, err)
+	assert.Equal(t, []ZMember{{Member: "x", Score: 1}, {Member: "y", Score: 2}}, members)
+	members, err = m.ZRange(0, "z", 5, 10)
+	assert.NoError(t, err)
+	assert.Empty(t, members)
+
+	report := m.Report()
+	assert.Equal(t, 4+7, report.Applied)
+	assert.Equal(t, 0, report.Failed)
+
+	// A full synchronization replaces the data set.
+	m.Apply(&replica.StreamEvent{Type: replica.StreamEventTypeFullResync})
+	_, ok, _ = m.Get(0, "c")
+	assert.False(t, ok)
+}
+
+func TestMirror_Scan(t *testing.T) {
+	m := newTestMirror(t, func(w *rdb.Writer) error {
+		return nil
+	})
+	apply(m, 0,
+		"SET user:1 a",
+		"SET user:2 b",
+		"SET user:3 c",
+		"SET order:1 d",
+		"SET user:4 e PXAT 1",
+	)
+
+	var keys []string
+	cursor := ""
+	for {
+		var page []string
+		page, cursor = m.Scan(0, cursor, "user:*", 2)
+		assert.LessOrEqual(t, len(page), 2)
+		keys = append(keys, page...)
+		if cursor == "" {
+			break
+		}
+	}
+	assert.Equal(t, []string{"user:1", "user:2", "user:3"}, keys)
+
+	keys, cursor = m.Scan(0, "", "", 0)
+	assert.Equal(t, []string{"order:1", "user:1", "user:2", "user:3"}, keys)
+	assert.Equal(t, "", cursor)
+}
+
+func TestMirror_Failures(t *testing.T) {
+	var handled []*Failure
+	m := newTestMirror(t, func(w *rdb.Writer) error {
+		return nil
+	}, WithFailureHandler(func(f *Failure) {
+		handled = append(handled, f)
+	}))
+	apply(m, 0,
+		"SET a 1",
+		"XGROUP CREATE s g $ MKSTREAM",
+		"SADD a m",
+	)
+
+	report := m.Report()
+	assert.Equal(t, 1, report.Applied)
+	assert.Equal(t, 2, report.Failed)
+	assert.Equal(t, map[string]int{"XGROUP": 1, "SADD": 1}, report.FailedByName)
+	assert.Equal(t, report.Failures, handled)
+	var unsupported *keyspace.UnsupportedCommandError
+	assert.True(t, errors.As(report.Failures[0].Err, &unsupported))
+	assert.True(t, errors.Is(report.Failures[1].Err, ErrWrongType))
+}
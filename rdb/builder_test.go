@@ -0,0 +1,94 @@
+package rdb
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// rdbBuilder assembles RDB files for tests.
+type rdbBuilder struct {
+	bytes.Buffer
+}
+
+func newRdbBuilder(version string) *rdbBuilder {
+	b := &rdbBuilder{}
+	b.WriteString("REDIS")
+	b.WriteString(version)
+	return b
+}
+
+func (b *rdbBuilder) length(n int) {
+	switch {
+	case n < 1<<6:
+		b.WriteByte(byte(n))
+	case n < 1<<14:
+		b.WriteByte(byte(n>>8) | 0x40)
+		b.WriteByte(byte(n))
+	default:
+		b.WriteByte(0x80)
+		_ = binary.Write(b, binary.BigEndian, uint32(n))
+	}
+}
+
+func (b *rdbBuilder) str(s string) {
+	b.length(len(s))
+	b.WriteString(s)
+}
+
+func (b *rdbBuilder) aux(field, value string) {
+	b.WriteByte(opCodeAux)
+	b.str(field)
+	b.str(value)
+}
+
+// auxInt writes an aux field with an integer encoded value.
+func (b *rdbBuilder) auxInt(field string, v int32) {
+	b.WriteByte(opCodeAux)
+	b.str(field)
+	b.WriteByte(0xC2)
+	_ = binary.Write(b, binary.LittleEndian, v)
+}
+
+func (b *rdbBuilder) selectDb(db int) {
+	b.WriteByte(opCodeSelectDb)
+	b.length(db)
+}
+
+func (b *rdbBuilder) expireMs(ms int64) {
+	b.WriteByte(opExpireTimeMs)
+	_ = binary.Write(b, binary.LittleEndian, uint64(ms))
+}
+
+func (b *rdbBuilder) stringObject(key, value string) {
+	b.WriteByte(rdbTypeString)
+	b.str(key)
+	b.str(value)
+}
+
+func (b *rdbBuilder) setObject(key string, members ...string) {
+	b.WriteByte(rdbTypeSet)
+	b.str(key)
+	b.length(len(members))
+	for _, m := range members {
+		b.str(m)
+	}
+}
+
+func (b *rdbBuilder) eof() {
+	b.WriteByte(opCodeEOF)
+	b.Write(make([]byte, 8))
+}
+
+// listPack writes a listpack of small strings as a string.
+func (b *rdbBuilder) listPack(members ...string) {
+	var lp []byte
+	for _, m := range members {
+		lp = append(lp, lpEncoding6BitStr|byte(len(m)))
+		lp = append(lp, m...)
+		lp = append(lp, byte(1+len(m)))
+	}
+	header := make([]byte, 6)
+	binary.LittleEndian.PutUint32(header, uint32(len(header)+len(lp)+1))
+	binary.LittleEndian.PutUint16(header[4:], uint16(len(members)))
+	b.str(string(append(append(header, lp...), 0xff)))
+}
@@ -0,0 +1,50 @@
+package rdb
+
+import (
+	"fmt"
+	"io"
+)
+
+// Checkpoint is the parser state between two records of an RDB file. It is
+// taken after an event, where no expire time, idle time or frequency is
+// pending for the next key.
+type Checkpoint struct {
+	// Offset of the next record from the start of the file.
+	Offset int64
+
+	// RDB version from the file header.
+	Version int
+
+	// Currently selected database.
+	Db int
+}
+
+func (p *Parser) takeCheckpoint() Checkpoint {
+	return Checkpoint{
+		Offset:  p.r.Offset(),
+		Version: p.version,
+		Db:      p.db,
+	}
+}
+
+// restore moves the reader, positioned right after the file header, to the
+// checkpoint and restores the parsing state.
+func (p *Parser) restore(cp Checkpoint) error {
+	if cp.Version != p.version {
+		return fmt.Errorf("checkpoint version %d does not match rdb version %d", cp.Version, p.version)
+	}
+	if cp.Offset < p.r.Offset() {
+		return fmt.Errorf("checkpoint offset %d is inside the rdb header", cp.Offset)
+	}
+	if p.fd == nil {
+		return fmt.Errorf("resuming from a checkpoint requires a file")
+	}
+	if _, err := p.fd.Seek(cp.Offset, io.SeekStart); err != nil {
+		return err
+	}
+	p.r = newRdbReader(p.fd)
+	p.r.offset = cp.Offset
+
+	p.db = cp.Db
+	return nil
+}
@@ -0,0 +1,76 @@
+package rdb
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewParserAt(t *testing.T) {
+	b := newRdbBuilder("0009")
+	b.aux("redis-ver", "7.0.0")
+	b.selectDb(0)
+	b.stringObject("a", "1")
+	b.selectDb(2)
+	b.stringObject("b", "2")
+	b.expireMs(1700000000000)
+	b.stringObject("c", "3")
+	b.stringObject("d", "4")
+	b.eof()
+
+	name := filepath.Join(t.TempDir(), "dump.rdb")
+	if err := os.WriteFile(name, b.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := NewParser(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := p.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cp Checkpoint
+	for s.HasNext() {
+		e := s.Next()
+		if e.EventType == EventTypeStringObject && e.Event.(*StringObjectEvent).Key == "b" {
+			cp = s.Checkpoint()
+		}
+	}
+	if err := s.Err(); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 9, cp.Version)
+	assert.Equal(t, 2, cp.Db)
+
+	p, err = NewParserAt(name, cp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := collectEvents(t, p)
+	assert.Equal(t, []string{"c", "d"}, stringKeys(events))
+	var expires []int64
+	for _, e := range events {
+		if e.EventType == EventTypeStringObject {
+			assert.Equal(t, 2, e.Event.(*StringObjectEvent).DbId)
+			expires = append(expires, e.Event.(*StringObjectEvent).ExpireAt())
+		}
+	}
+	// The expire time read after the checkpoint applies to the next key only.
+	assert.Equal(t, []int64{1700000000000, -1}, expires)
+
+	cp.Version = 10
+	p, err = NewParserAt(name, cp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err = p.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for s.HasNext() {
+	}
+	assert.Error(t, s.Err())
+}
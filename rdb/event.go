@@ -1,6 +1,10 @@
 package rdb
 
-import "fmt"
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+)
 
 type EventType uint8
 
@@ -16,6 +20,9 @@ const (
 	EventTypeZSetObject
 	EventTypeHashObject
 	EventTypeStreamObject
+	EventTypeCorruptEntry
+	EventTypeRecoverySummary
+	EventTypeLuaScript
 )
 
 type RedisRdbEvent struct {
@@ -58,6 +65,32 @@ func (e *AuxFieldEvent) Debug() {
 	fmt.Printf("\n")
 }
 
+// LuaScriptEvent is a script persisted in a "lua" aux field, it follows the
+// AuxFieldEvent of the field. Redis before 7.0 saves the scripts of EVAL in
+// the RDB sent to replicas, so that EVALSHA works after the replica loaded
+// it.
+type LuaScriptEvent struct {
+	Script string
+
+	// Lowercase hex SHA1 of the script, as used by EVALSHA.
+	Sha1 string
+}
+
+func newLuaScriptEvent(script string) *LuaScriptEvent {
+	sum := sha1.Sum([]byte(script))
+	return &LuaScriptEvent{
+		Script: script,
+		Sha1:   hex.EncodeToString(sum[:]),
+	}
+}
+
+func (e *LuaScriptEvent) Debug() {
+	fmt.Printf("=== LuaScriptEvent ===\n")
+	fmt.Printf("SHA1: %s\n", e.Sha1)
+	fmt.Printf("%s\n", e.Script)
+	fmt.Printf("\n")
+}
+
 type SelectDbEvent struct {
 	Db int
 }
@@ -0,0 +1,270 @@
+package rdb
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+const indexMagic = "RDBIDX1"
+
+// Maximum length of a key in an index, as proto-max-bulk-len.
+const maxIndexKeyLen = 512 << 20
+
+var ErrKeyNotFound = errors.New("key not found in index")
+
+// IndexEntry locates a key-value record inside an RDB file.
+type IndexEntry struct {
+	Db   int
+	Key  string
+	Type EventType
+
+	// Position of the record, starting at its value type byte.
+	Offset int64
+	Length int64
+
+	// Millisecond, -1 if the key has no expiry.
+	ExpireAt int64
+}
+
+// Index maps keys to their records in an RDB file, for random access with
+// IndexReader. Entries are sorted by database and key.
+type Index struct {
+	// RDB version of the indexed file.
+	Version int
+
+	Entries []IndexEntry
+}
+
+// BuildIndex parses the whole file with p and indexes every key.
+func BuildIndex(p *Parser) (*Index, error) {
+	s, err := p.Parse()
+	if err != nil {
+		return nil, err
+	}
+
+	idx := new(Index)
+	for s.HasNext() {
+		e := s.Next()
+		var key RedisKey
+		switch event := e.Event.(type) {
+		case *VersionEvent:
+			idx.Version = event.Version
+			continue
+		case *StringObjectEvent:
+			key = event.RedisKey
+		case *ListObjectEvent:
+			key = event.RedisKey
+		case *SetObjectEvent:
+			key = event.RedisKey
+		case *ZSetObjectEvent:
+			key = event.RedisKey
+		case *HashObjectEvent:
+			key = event.RedisKey
+		case *StreamObjectEvent:
+			key = event.RedisKey
+		default:
+			continue
+		}
+		idx.Entries = append(idx.Entries, IndexEntry{
+			Db:       key.DbId,
+			Key:      key.Key,
+			Type:     e.EventType,
+			Offset:   s.offset,
+			Length:   s.checkpoint.Offset - s.offset,
+			ExpireAt: key.expireAt,
+		})
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(idx.Entries, func(i, j int) bool {
+		return idx.Entries[i].less(idx.Entries[j].Db, idx.Entries[j].Key)
+	})
+	return idx, nil
+}
+
+func (e *IndexEntry) less(db int, key string) bool {
+	if e.Db != db {
+		return e.Db < db
+	}
+	return e.Key < key
+}
+
+// search returns the position of the first entry not less than (db, key).
+func (idx *Index) search(db int, key string) int {
+	return sort.Search(len(idx.Entries), func(i int) bool {
+		return !idx.Entries[i].less(db, key)
+	})
+}
+
+// Lookup returns the entry of key in database db.
+func (idx *Index) Lookup(db int, key string) (IndexEntry, bool) {
+	i := idx.search(db, key)
+	if i < len(idx.Entries) && idx.Entries[i].Db == db && idx.Entries[i].Key == key {
+		return idx.Entries[i], true
+	}
+	return IndexEntry{}, false
+}
+
+// Prefix returns the entries of database db whose key starts with prefix,
+// in key order.
+func (idx *Index) Prefix(db int, prefix string) []IndexEntry {
+	i := idx.search(db, prefix)
+	j := i
+	for j < len(idx.Entries) && idx.Entries[j].Db == db && strings.HasPrefix(idx.Entries[j].Key, prefix) {
+		j++
+	}
+	return idx.Entries[i:j]
+}
+
+// WriteTo writes the index in its sidecar file format.
+func (idx *Index) WriteTo(w io.Writer) (int64, error) {
+	bw := bufio.NewWriter(w)
+	var n int64
+	buf := make([]byte, binary.MaxVarintLen64)
+	write := func(b []byte) {
+		m, _ := bw.Write(b)
+		n += int64(m)
+	}
+	writeUvarint := func(v uint64) {
+		write(buf[:binary.PutUvarint(buf, v)])
+	}
+
+	write([]byte(indexMagic))
+	writeUvarint(uint64(idx.Version))
+	writeUvarint(uint64(len(idx.Entries)))
+	for _, e := range idx.Entries {
+		writeUvarint(uint64(e.Db))
+		writeUvarint(uint64(e.Type))
+		writeUvarint(uint64(e.Offset))
+		writeUvarint(uint64(e.Length))
+		write(buf[:binary.PutVarint(buf, e.ExpireAt)])
+		writeUvarint(uint64(len(e.Key)))
+		write([]byte(e.Key))
+	}
+	return n, bw.Flush()
+}
+
+// ReadIndex reads an index written by Index.WriteTo.
+func ReadIndex(r io.Reader) (*Index, error) {
+	br := bufio.NewReader(r)
+	magic := make([]byte, len(indexMagic))
+	if _, err := io.ReadFull(br, magic); err != nil {
+		return nil, err
+	}
+	if string(magic) != indexMagic {
+		return nil, fmt.Errorf("invalid index magic: %q", magic)
+	}
+
+	version, err := binary.ReadUvarint(br)
+	if err != nil {
+		return nil, err
+	}
+	count, err := binary.ReadUvarint(br)
+	if err != nil {
+		return nil, err
+	}
+
+	idx := &Index{
+		Version: int(version),
+		Entries: make([]IndexEntry, 0, allocHint(int(count))),
+	}
+	for i := uint64(0); i < count; i++ {
+		// Db, type, offset and length.
+		var fields [4]uint64
+		for j := range fields {
+			if fields[j], err = binary.ReadUvarint(br); err != nil {
+				return nil, unexpectedEOF(err)
+			}
+		}
+		expireAt, err := binary.ReadVarint(br)
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		keyLen, err := binary.ReadUvarint(br)
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		if keyLen > maxIndexKeyLen {
+			return nil, fmt.Errorf("invalid index key length %d", keyLen)
+		}
+		// The key is read as it arrives, a corrupt length fails at the end
+		// of the index instead of allocating it up front.
+		var key bytes.Buffer
+		if _, err := io.CopyN(&key, br, int64(keyLen)); err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		idx.Entries = append(idx.Entries, IndexEntry{
+			Db:       int(fields[0]),
+			Type:     EventType(fields[1]),
+			Offset:   int64(fields[2]),
+			Length:   int64(fields[3]),
+			ExpireAt: expireAt,
+			Key:      key.String(),
+		})
+	}
+	return idx, nil
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// IndexReader decodes single keys of an RDB file on demand.
+type IndexReader struct {
+	ra    io.ReaderAt
+	index *Index
+}
+
+func NewIndexReader(ra io.ReaderAt, index *Index) *IndexReader {
+	return &IndexReader{
+		ra:    ra,
+		index: index,
+	}
+}
+
+// Get decodes key of database db. ErrKeyNotFound is returned if the key is
+// not in the index.
+func (r *IndexReader) Get(db int, key string) (*RedisRdbEvent, error) {
+	e, ok := r.index.Lookup(db, key)
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+	return r.Decode(e)
+}
+
+// Decode decodes the record of an index entry. An error is returned if the
+// record is corrupt, e.g. the index does not match the file.
+func (r *IndexReader) Decode(e IndexEntry) (ev *RedisRdbEvent, err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			ev, err = nil, fmt.Errorf("corrupt value of key %q: %v", e.Key, v)
+		}
+	}()
+	p := &Parser{
+		r:       newRdbReader(io.NewSectionReader(r.ra, e.Offset, e.Length)),
+		version: r.index.Version,
+	}
+	valueType, err := p.r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	key, err := p.parseKey()
+	if err != nil {
+		return nil, err
+	}
+	if key != e.Key {
+		return nil, fmt.Errorf("index entry of key %q points to key %q", e.Key, key)
+	}
+	return p.parseEntryWithValueType(valueType, key, e.Db, e.ExpireAt)
+}
@@ -0,0 +1,106 @@
+package rdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"testing"
+)
+
+func TestIndex(t *testing.T) {
+	b := newRdbBuilder("0009")
+	b.selectDb(0)
+	b.stringObject("user:1", "alice")
+	b.expireMs(1700000000000)
+	b.stringObject("user:2", "bob")
+	b.setObject("tags", "a", "b")
+	b.selectDb(1)
+	b.stringObject("user:1", "carol")
+	b.eof()
+	data := b.Bytes()
+
+	p, err := NewReaderParser(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx, err := BuildIndex(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 9, idx.Version)
+	assert.Len(t, idx.Entries, 4)
+
+	var buf bytes.Buffer
+	if _, err := idx.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	idx, err = ReadIndex(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var keys []string
+	for _, e := range idx.Prefix(0, "user:") {
+		keys = append(keys, e.Key)
+	}
+	assert.Equal(t, []string{"user:1", "user:2"}, keys)
+
+	r := NewIndexReader(bytes.NewReader(data), idx)
+	e, err := r.Get(0, "user:2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	str := e.Event.(*StringObjectEvent)
+	assert.Equal(t, "bob", str.Value)
+	assert.Equal(t, int64(1700000000000), str.expireAt)
+
+	e, err = r.Get(1, "user:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "carol", e.Event.(*StringObjectEvent).Value)
+
+	e, err = r.Get(0, "tags")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"a", "b"}, e.Event.(*SetObjectEvent).Members)
+
+	_, err = r.Get(1, "tags")
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+}
+
+func TestReadIndex_Corrupt(t *testing.T) {
+	header := func(keyLen uint64) []byte {
+		b := []byte(indexMagic)
+		b = binary.AppendUvarint(b, 9)
+		b = binary.AppendUvarint(b, 1)
+		for i := 0; i < 4; i++ {
+			b = binary.AppendUvarint(b, 0)
+		}
+		b = binary.AppendVarint(b, -1)
+		return binary.AppendUvarint(b, keyLen)
+	}
+
+	_, err := ReadIndex(bytes.NewReader(header(1 << 62)))
+	assert.Error(t, err)
+
+	_, err = ReadIndex(bytes.NewReader(append(header(1<<20), "key"...)))
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
+
+func TestIndexReader_DecodeCorrupt(t *testing.T) {
+	b := newRdbBuilder("0009")
+	start := b.Len()
+	b.WriteByte(rdbTypeIntSet)
+	b.str("s")
+	// Intset of encoding 0 with 2^32-1 members.
+	b.str("\x00\x00\x00\x00\xff\xff\xff\xff")
+	data := b.Bytes()
+
+	idx := &Index{Version: 9}
+	r := NewIndexReader(bytes.NewReader(data), idx)
+	_, err := r.Decode(IndexEntry{Offset: int64(start), Length: int64(len(data) - start), ExpireAt: -1, Key: "s"})
+	assert.Error(t, err)
+}
@@ -1,6 +1,9 @@
 package rdb
 
-import "bytes"
+import (
+	"bytes"
+	"fmt"
+)
 
 func parseIntSet(r *rdbReader) ([]int64, error) {
 	b, err := r.GetLengthBytes()
@@ -18,6 +21,13 @@ func parseIntSet(r *rdbReader) ([]int64, error) {
 		return nil, err
 	}
 
+	if encoding != 2 && encoding != 4 && encoding != 8 {
+		return nil, fmt.Errorf("invalid intset encoding %d", encoding)
+	}
+	if int64(length)*int64(encoding) > int64(len(b)) {
+		return nil, fmt.Errorf("intset length %d exceeds blob size %d", length, len(b))
+	}
+
 	members := make([]int64, length)
 	for i := 0; i < int(length); i++ {
 		switch encoding {
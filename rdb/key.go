@@ -14,6 +14,12 @@ type RedisKey struct {
 	expireAt int64
 }
 
+// ExpireAt returns the expire time of the key in milliseconds since the
+// epoch, or -1 if the key does not expire.
+func (k RedisKey) ExpireAt() int64 {
+	return k.expireAt
+}
+
 func (k RedisKey) debugKey() {
 	fmt.Printf("DbId: %d\n", k.DbId)
 	fmt.Printf("Key: %s\n", k.Key)
@@ -0,0 +1,81 @@
+package rdb
+
+import (
+	"strconv"
+	"time"
+)
+
+// RdbMetadata is the information Redis stores in the RDB header and
+// auxiliary fields. Fields missing from the file keep their zero value.
+type RdbMetadata struct {
+	Version int
+
+	// Version of the Redis server that created the file.
+	RedisVer string
+
+	// Architecture word size of the server, 32 or 64.
+	RedisBits int
+
+	// Creation time of the file.
+	CTime time.Time
+
+	// Memory used by the server when the file was created.
+	UsedMem int64
+
+	// Replication ID and offset of the master at the time of the snapshot.
+	// A replica loading this file can continue with
+	// PSYNC <ReplId> <ReplOffset+1>.
+	ReplId     string
+	ReplOffset int64
+
+	// Database selected in the replication stream, -1 if unknown.
+	ReplStreamDb int
+
+	// Whether the file is the base of a multi part AOF.
+	AofBase bool
+
+	// Lua scripts persisted for replication by Redis before 7.0.
+	LuaScripts []string
+
+	// Last value of every auxiliary field, including the ones above.
+	Aux map[string]string
+}
+
+func (m *RdbMetadata) update(e *RedisRdbEvent) {
+	switch event := e.Event.(type) {
+	case *VersionEvent:
+		m.Version = event.Version
+	case *AuxFieldEvent:
+		m.setAuxField(event.Filed, event.Value)
+	}
+}
+
+// rdb.c::rdbSaveInfoAuxFields
+func (m *RdbMetadata) setAuxField(field, value string) {
+	m.Aux[field] = value
+
+	switch field {
+	case "redis-ver":
+		m.RedisVer = value
+	case "redis-bits":
+		m.RedisBits, _ = strconv.Atoi(value)
+	case "ctime":
+		if sec, err := strconv.ParseInt(value, 10, 64); err == nil {
+			m.CTime = time.Unix(sec, 0)
+		}
+	case "used-mem":
+		m.UsedMem, _ = strconv.ParseInt(value, 10, 64)
+	case "repl-id":
+		m.ReplId = value
+	case "repl-offset":
+		m.ReplOffset, _ = strconv.ParseInt(value, 10, 64)
+	case "repl-stream-db":
+		if db, err := strconv.Atoi(value); err == nil {
+			m.ReplStreamDb = db
+		}
+	case "aof-base":
+		m.AofBase = value == "1"
+	case "lua":
+		m.LuaScripts = append(m.LuaScripts, value)
+	}
+}
@@ -0,0 +1,89 @@
+package rdb
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestEventStreamer_Metadata(t *testing.T) {
+	b := newRdbBuilder("0009")
+	b.aux("redis-ver", "6.2.6")
+	b.auxInt("redis-bits", 64)
+	b.auxInt("ctime", 1700000000)
+	b.auxInt("used-mem", 868776)
+	b.auxInt("repl-stream-db", -1)
+	b.aux("repl-id", "8c8bb1d4a4b8f1e9b2c94c5b3a1b2e3f4d5c6b7a")
+	b.aux("repl-offset", "123456789012")
+	b.auxInt("aof-base", 0)
+	b.aux("lua", "return 1")
+	b.selectDb(0)
+	b.stringObject("a", "1")
+	b.eof()
+
+	p, err := NewReaderParser(bytes.NewReader(b.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := p.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for s.HasNext() {
+		if s.Next().EventType == EventTypeSelectDb {
+			break
+		}
+	}
+
+	md := s.Metadata()
+	assert.Equal(t, 9, md.Version)
+	assert.Equal(t, "6.2.6", md.RedisVer)
+	assert.Equal(t, 64, md.RedisBits)
+	assert.Equal(t, time.Unix(1700000000, 0), md.CTime)
+	assert.Equal(t, int64(868776), md.UsedMem)
+	assert.Equal(t, -1, md.ReplStreamDb)
+	assert.Equal(t, "8c8bb1d4a4b8f1e9b2c94c5b3a1b2e3f4d5c6b7a", md.ReplId)
+	assert.Equal(t, int64(123456789012), md.ReplOffset)
+	assert.False(t, md.AofBase)
+	assert.Equal(t, []string{"return 1"}, md.LuaScripts)
+	assert.Equal(t, "-1", md.Aux["repl-stream-db"])
+
+	for s.HasNext() {
+	}
+	assert.NoError(t, s.Err())
+}
+
+func TestParser_LuaScript(t *testing.T) {
+	b := newRdbBuilder("0009")
+	b.aux("redis-ver", "6.2.6")
+	b.aux("lua", "return 1")
+	b.aux("lua", "return redis.call('GET', KEYS[1])")
+	b.eof()
+
+	p, err := NewReaderParser(bytes.NewReader(b.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		scripts []*LuaScriptEvent
+		luaAux  int
+	)
+	for _, e := range collectEvents(t, p) {
+		switch e.EventType {
+		case EventTypeLuaScript:
+			scripts = append(scripts, e.Event.(*LuaScriptEvent))
+		case EventTypeAuxField:
+			if e.Event.(*AuxFieldEvent).Filed == "lua" {
+				luaAux++
+			}
+		}
+	}
+	// The aux fields are still reported.
+	assert.Equal(t, 2, luaAux)
+	if assert.Len(t, scripts, 2) {
+		assert.Equal(t, "return 1", scripts[0].Script)
+		assert.Equal(t, "e0e1f9fabfc9d4800c877a703b823ac0578ff8db", scripts[0].Sha1)
+		assert.Equal(t, "return redis.call('GET', KEYS[1])", scripts[1].Script)
+	}
+}
@@ -48,7 +48,7 @@ func parseHash0(r *rdbReader, h *HashObjectEvent) (*HashObjectEvent, error) {
 	if err != nil {
 		return nil, err
 	}
-	fields := make([]HashField, dictSize)
+	fields := make([]HashField, 0, allocHint(dictSize))
 	for i := 0; i < dictSize; i++ {
 		field, err := r.GetLengthString()
 		if err != nil {
@@ -58,10 +58,10 @@ func parseHash0(r *rdbReader, h *HashObjectEvent) (*HashObjectEvent, error) {
 		if err != nil {
 			return nil, err
 		}
-		fields[i] = HashField{
+		fields = append(fields, HashField{
 			Field: field,
 			Value: value,
-		}
+		})
 	}
 	h.Fields = fields
 
@@ -46,13 +46,13 @@ func parseList0(r *rdbReader, list *ListObjectEvent) (*ListObjectEvent, error) {
 	if err != nil {
 		return nil, err
 	}
-	members := make([]string, size)
+	members := make([]string, 0, allocHint(size))
 	for i := 0; i < size; i++ {
 		item, err := r.GetLengthString()
 		if err != nil {
 			return nil, err
 		}
-		members[i] = item
+		members = append(members, item)
 	}
 	list.Elements = members
 	return list, nil
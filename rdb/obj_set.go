@@ -43,13 +43,13 @@ func parseSet0(r *rdbReader, set *SetObjectEvent) (*SetObjectEvent, error) {
 	if err != nil {
 		return nil, err
 	}
-	members := make([]string, size)
+	members := make([]string, 0, allocHint(size))
 	for i := 0; i < size; i++ {
 		item, err := r.GetLengthString()
 		if err != nil {
 			return nil, err
 		}
-		members[i] = item
+		members = append(members, item)
 	}
 	set.Members = members
 
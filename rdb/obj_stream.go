@@ -17,6 +17,9 @@ type StreamObjectEvent struct {
 
 	Entries []*StreamEntry
 	Groups  []*StreamConsumerGroup
+
+	// Greatest ID ever added, entries up to it may have been deleted.
+	LastId StreamId
 }
 
 func (e *StreamObjectEvent) Debug() {
@@ -28,8 +31,8 @@ func (e *StreamObjectEvent) Debug() {
 	for _, e := range e.Entries {
 		id := fmt.Sprintf("%d-%d", e.Id.Ms, e.Id.Seq)
 		var fields []string
-		for field, value := range e.Fields {
-			fields = append(fields, fmt.Sprintf("%s=%s", field, value))
+		for _, f := range e.Fields {
+			fields = append(fields, fmt.Sprintf("%s=%s", f.Field, f.Value))
 		}
 		fmt.Printf("\tid=%s fields=%s\n", id, strings.Join(fields, ","))
 	}
@@ -46,8 +49,15 @@ func (e *StreamObjectEvent) Debug() {
 }
 
 type StreamEntry struct {
-	Id     StreamId
-	Fields map[string]string
+	Id StreamId
+
+	// Fields in the order they were added.
+	Fields []StreamField
+}
+
+type StreamField struct {
+	Field string
+	Value string
 }
 
 type StreamConsumerGroup struct {
@@ -95,7 +105,7 @@ func parseStream(key RedisKey, r *rdbReader, valueType byte) (*StreamObjectEvent
 		RedisKey: key,
 	}
 	switch valueType {
-	case rdbTypeStreamListPacks, rdbTypeListQuickList2:
+	case rdbTypeStreamListPacks, rdbTypeStreamListPacks2:
 		return parseStream0(r, valueType, stream)
 	default:
 		return nil, fmt.Errorf("unsupported stream rdb type: 0x%x", valueType)
@@ -203,20 +213,20 @@ func parseStream0(r *rdbReader, valueType byte, stream *StreamObjectEvent) (*Str
 					return nil, err
 				}
 				mIndex++
-				entry.Fields = make(map[string]string, int(nFields))
+				entry.Fields = make([]StreamField, 0, int(nFields))
 				for i := 0; i < int(nFields); i++ {
 					field := members[mIndex]
 					value := members[mIndex+1]
 					mIndex += 2
-					entry.Fields[field] = value
+					entry.Fields = append(entry.Fields, StreamField{Field: field, Value: value})
 				}
 			} else {
-				entry.Fields = make(map[string]string, int(masterNumFields))
+				entry.Fields = make([]StreamField, 0, int(masterNumFields))
 				for i := 0; i < int(masterNumFields); i++ {
 					field := masterFields[i]
 					value := members[mIndex]
 					mIndex++
-					entry.Fields[field] = value
+					entry.Fields = append(entry.Fields, StreamField{Field: field, Value: value})
 				}
 			}
 
@@ -247,8 +257,7 @@ func parseStream0(r *rdbReader, valueType byte, stream *StreamObjectEvent) (*Str
 	if err != nil {
 		return nil, err
 	}
-	_ = lastIdMs
-	_ = lastIdSeq
+	stream.LastId = StreamId{Ms: lastIdMs, Seq: lastIdSeq}
 
 	if valueType == rdbTypeStreamListPacks2 {
 		// Load the first entry ID.
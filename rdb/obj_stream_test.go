@@ -0,0 +1,37 @@
+package rdb
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseStreamWithListPacks(t *testing.T) {
+	b := &rdbBuilder{}
+	b.length(1)
+	// Master ID 1-1.
+	b.str("\x00\x00\x00\x00\x00\x00\x00\x01\x00\x00\x00\x00\x00\x00\x00\x01")
+	b.listPack(
+		// Master entry with the fields b and a.
+		"2", "0", "2", "b", "a", "0",
+		// 1-1 with the master fields.
+		"2", "0", "0", "1", "2", "4",
+		// 1-2 with its own fields.
+		"0", "0", "1", "2", "z", "3", "y", "4", "7",
+	)
+	// Length, last ID 1-2 and no consumer group.
+	b.length(2)
+	b.length(1)
+	b.length(2)
+	b.length(0)
+
+	e, err := parseStream(RedisKey{}, newRdbReader(bytes.NewReader(b.Bytes())), rdbTypeStreamListPacks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []*StreamEntry{
+		{Id: StreamId{Ms: 1, Seq: 1}, Fields: []StreamField{{Field: "b", Value: "1"}, {Field: "a", Value: "2"}}},
+		{Id: StreamId{Ms: 1, Seq: 2}, Fields: []StreamField{{Field: "z", Value: "3"}, {Field: "y", Value: "4"}}},
+	}, e.Entries)
+	assert.Equal(t, StreamId{Ms: 1, Seq: 2}, e.LastId)
+}
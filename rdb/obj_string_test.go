@@ -22,3 +22,24 @@ func TestParseString(t *testing.T) {
 	}
 	assert.Equal(t, "aaaa", e.Value)
 }
+
+func TestParseString_Integer(t *testing.T) {
+	// Integer encoded strings are signed, rdb.c::rdbLoadIntegerObject.
+	for _, c := range []struct {
+		in   []byte
+		want string
+	}{
+		{[]byte{0xC0, 0x7F}, "127"},
+		{[]byte{0xC0, 0xFB}, "-5"},
+		{[]byte{0xC1, 0xC8, 0x00}, "200"},
+		{[]byte{0xC1, 0xD4, 0xFE}, "-300"},
+		{[]byte{0xC2, 0x90, 0xEE, 0xFE, 0xFF}, "-70000"},
+		{[]byte{0xC2, 0xFF, 0xFF, 0xFF, 0x7F}, "2147483647"},
+	} {
+		e, err := parseString(RedisKey{}, newRdbReader(bytes.NewReader(c.in)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, c.want, e.Value, c.in)
+	}
+}
@@ -50,7 +50,7 @@ func parseZSet0(r *rdbReader, set *ZSetObjectEvent) (*ZSetObjectEvent, error) {
 	if err != nil {
 		return nil, err
 	}
-	members := make([]ZSetMember, length)
+	members := make([]ZSetMember, 0, allocHint(length))
 	for i := 0; i < length; i++ {
 		value, err := r.GetLengthString()
 		if err != nil {
@@ -60,8 +60,7 @@ func parseZSet0(r *rdbReader, set *ZSetObjectEvent) (*ZSetObjectEvent, error) {
 		if err != nil {
 			return nil, err
 		}
-		members[i].Value = value
-		members[i].Score = score
+		members = append(members, ZSetMember{Value: value, Score: score})
 	}
 	set.Members = members
 
@@ -73,7 +72,7 @@ func parseZSet2(r *rdbReader, set *ZSetObjectEvent) (*ZSetObjectEvent, error) {
 	if err != nil {
 		return nil, err
 	}
-	members := make([]ZSetMember, length)
+	members := make([]ZSetMember, 0, allocHint(length))
 	for i := 0; i < length; i++ {
 		value, err := r.GetLengthString()
 		if err != nil {
@@ -83,8 +82,7 @@ func parseZSet2(r *rdbReader, set *ZSetObjectEvent) (*ZSetObjectEvent, error) {
 		if err != nil {
 			return nil, err
 		}
-		members[i].Value = value
-		members[i].Score = score
+		members = append(members, ZSetMember{Value: value, Score: score})
 	}
 	set.Members = members
 
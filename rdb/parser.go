@@ -50,19 +50,70 @@ type Parser struct {
 	r    *rdbReader
 
 	version int
+
+	// Parsing state carried between records.
+	db       int
+	expireAt int64
+	idle     int64
+	freq     int
+	key      string
+
+	// Offset of the record being parsed.
+	recordOffset int64
+
+	// Resume parsing from here instead of the first record, see NewParserAt.
+	checkpoint *Checkpoint
+
+	// Skip corrupt entries instead of aborting, see WithRecovery.
+	recovery bool
 }
 
-func NewParser(name string) (*Parser, error) {
+type ParserOption func(p *Parser)
+
+// WithRecovery makes the parser tolerate corrupt or truncated files.
+// When a record fails to decode, a CorruptEntryEvent is reported and the
+// parser scans forward for the next plausible record. At the end of the file
+// a RecoverySummaryEvent reports how much data was skipped.
+//
+// Each record is kept in memory while it is parsed, so that the parser can
+// go back to it, and the scan may read ahead up to 8 MB. Memory use grows
+// with the largest record of the file.
+func WithRecovery() ParserOption {
+	return func(p *Parser) {
+		p.recovery = true
+	}
+}
+
+func NewParser(name string, opts ...ParserOption) (*Parser, error) {
 	p := &Parser{
 		file: name,
 	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p, nil
+}
+
+// NewParserAt creates a parser that continues parsing name from a checkpoint
+// taken by an earlier run over the same file. Only the magic number and
+// version events are repeated; parsing resumes with the record at
+// checkpoint.Offset.
+func NewParserAt(name string, checkpoint Checkpoint, opts ...ParserOption) (*Parser, error) {
+	p, err := NewParser(name, opts...)
+	if err != nil {
+		return nil, err
+	}
+	p.checkpoint = &checkpoint
 	return p, nil
 }
 
-func NewReaderParser(r io.Reader) (*Parser, error) {
+func NewReaderParser(r io.Reader, opts ...ParserOption) (*Parser, error) {
 	p := &Parser{
 		r: newRdbReader(r),
 	}
+	for _, opt := range opts {
+		opt(p)
+	}
 	return p, nil
 }
 
@@ -107,12 +158,10 @@ func (p *Parser) parse(eventC chan *eventWrapper) error {
 		return err
 	}
 	magicEvent := &MagicNumberEvent{MagicNumber: magic}
-	eventC <- &eventWrapper{
-		e: &RedisRdbEvent{
-			EventType: EventTypeMagicNumber,
-			Event:     magicEvent,
-		},
-	}
+	p.emit(eventC, &RedisRdbEvent{
+		EventType: EventTypeMagicNumber,
+		Event:     magicEvent,
+	})
 
 	// version
 	version, err := p.r.ReadFixedBytes(4)
@@ -125,127 +174,85 @@ func (p *Parser) parse(eventC chan *eventWrapper) error {
 	}
 	p.version = versionNumber
 	versionEvent := &VersionEvent{Version: versionNumber}
-	eventC <- &eventWrapper{
-		e: &RedisRdbEvent{
-			EventType: EventTypeVersion,
-			Event:     versionEvent,
-		},
-	}
-
-	dbId := 0
-	var expireTime int64 = -1
+	p.emit(eventC, &RedisRdbEvent{
+		EventType: EventTypeVersion,
+		Event:     versionEvent,
+	})
 
-	var isEnd bool
-	for !isEnd {
-		rdbType, err := p.r.ReadByte()
-		if err != nil {
+	p.db = 0
+	p.resetKeyState()
+	if p.checkpoint != nil {
+		if err := p.restore(*p.checkpoint); err != nil {
 			return err
 		}
-		switch rdbType {
-		case opExpireTime:
-			expireTime, err = p.parseExpireTime()
-			if err != nil {
-				return err
-			}
-			continue
-		case opExpireTimeMs:
-			expireTime, err = p.parseExpireTimeMs()
-			if err != nil {
-				return err
-			}
-			continue
-		case opCodeFreq:
-			// LFU frequency.
-			if err := p.parseFreq(); err != nil {
-				return err
-			}
-			continue
-		case opCodeIdle:
-			// LRU idle time.
-			_, err := p.parseIdle()
-			if err != nil {
-				return err
-			}
-			continue
-		case opCodeEOF:
-			isEnd = true
-			continue
-		case opCodeSelectDb:
-			e, err := p.parseSelectDb()
-			if err != nil {
-				return err
-			}
-			dbId = e.Db
-			eventC <- &eventWrapper{
-				e: &RedisRdbEvent{
-					EventType: EventTypeSelectDb,
-					Event:     e,
-				},
-			}
-			continue
-		case opCodeResizeDb:
-			// RESIZEDB: Hint about the size of the keys in the currently
-			// selected data base, in order to avoid useless rehashing.
-			e, err := p.parseResizeDb()
-			if err != nil {
-				return err
-			}
-			eventC <- &eventWrapper{
-				e: &RedisRdbEvent{
-					EventType: EventTypeResizeDb,
-					Event:     e,
-				},
-			}
-			continue
-		case opCodeAux:
-			// AUX: generic string-string fields. Use to add state to RDB
-			// which is backward compatible. Implementations of RDB loading
-			// are required to skip AUX fields they don't understand.
-			//
-			// An AUX field is composed of two strings: key and value.
-			e, err := p.parseAuxiliaryFields()
-			if err != nil {
-				return err
-			}
-			eventC <- &eventWrapper{
-				e: &RedisRdbEvent{
-					EventType: EventTypeAuxField,
-					Event:     e,
-				},
+	}
+
+	summary := &RecoverySummaryEvent{}
+	for {
+		offset := p.r.Offset()
+		if p.recovery {
+			p.r.mark()
+		}
+
+		isEnd, err := p.parseRecord(eventC)
+		if err == nil {
+			if isEnd {
+				break
 			}
 			continue
-		case opCodeModuleAux:
-			// Load module data that is not related to the Redis key space.
-			// Such data can be potentially be stored both before and after the
-			// RDB keys-values section.
-			// TODO
-			continue
-		case opcodeFunction:
-			return fmt.Errorf("pre-release function format not supported")
-		case opCodeFunction2:
-			// TODO
-			return fmt.Errorf("function not suuported")
 		}
-
-		// Load object key.
-		key, err := p.parseKey()
-		if err != nil {
+		if !p.recovery {
 			return err
 		}
-		// Load object value.
-		e, err := p.parseEntryWithValueType(rdbType, key, dbId, expireTime)
-		if err != nil {
-			return err
+
+		p.resetKeyState()
+		consumed := p.r.Offset() - offset
+		p.r.rewind()
+		if consumed == 0 && err == io.EOF {
+			// Nothing left: the file ends on a record boundary.
+			summary.Truncated = true
+			break
 		}
-		eventC <- &eventWrapper{e: e}
 
-		// Reset state.
-		expireTime = -1
+		skipped, resyncErr := p.resync()
+		summary.CorruptEntries++
+		summary.SkippedBytes += skipped
+		p.emit(eventC, &RedisRdbEvent{
+			EventType: EventTypeCorruptEntry,
+			Event: &CorruptEntryEvent{
+				Offset:       offset,
+				Db:           p.db,
+				Key:          p.key,
+				Err:          err,
+				SkippedBytes: skipped,
+			},
+		})
+		if resyncErr != nil {
+			if resyncErr != io.EOF {
+				return resyncErr
+			}
+			summary.Truncated = true
+			break
+		}
+	}
+
+	if p.recovery {
+		p.r.unmark()
+		p.emit(eventC, &RedisRdbEvent{
+			EventType: EventTypeRecoverySummary,
+			Event:     summary,
+		})
+		if summary.Truncated {
+			return nil
+		}
 	}
 
 	if p.version >= 5 {
 		// TODO compare?
 		if _, err := p.r.ReadFixedBytes(8); err != nil {
+			if p.recovery {
+				return nil
+			}
 			return err
 		}
 	}
@@ -253,9 +260,143 @@ func (p *Parser) parse(eventC chan *eventWrapper) error {
 	return nil
 }
 
-func (p *Parser) parseFreq() error {
-	_, err := p.r.ReadByte()
-	return err
+// parseRecord parses one opcode or key-value pair and sends the resulting
+// event, if any. It reports whether the EOF opcode was reached.
+func (p *Parser) parseRecord(eventC chan *eventWrapper) (bool, error) {
+	p.key = ""
+	p.recordOffset = p.r.Offset()
+
+	rdbType, err := p.r.ReadByte()
+	if err != nil {
+		return false, err
+	}
+	switch rdbType {
+	case opExpireTime:
+		p.expireAt, err = p.parseExpireTime()
+		return false, err
+	case opExpireTimeMs:
+		p.expireAt, err = p.parseExpireTimeMs()
+		return false, err
+	case opCodeFreq:
+		// LFU frequency.
+		freq, err := p.parseFreq()
+		if err != nil {
+			return false, err
+		}
+		p.freq = int(freq)
+		return false, nil
+	case opCodeIdle:
+		// LRU idle time.
+		idle, err := p.parseIdle()
+		if err != nil {
+			return false, err
+		}
+		p.idle = int64(idle)
+		return false, nil
+	case opCodeEOF:
+		return true, nil
+	case opCodeSelectDb:
+		e, err := p.parseSelectDb()
+		if err != nil {
+			return false, err
+		}
+		p.db = e.Db
+		p.emit(eventC, &RedisRdbEvent{
+			EventType: EventTypeSelectDb,
+			Event:     e,
+		})
+		return false, nil
+	case opCodeResizeDb:
+		// RESIZEDB: Hint about the size of the keys in the currently
+		// selected data base, in order to avoid useless rehashing.
+		e, err := p.parseResizeDb()
+		if err != nil {
+			return false, err
+		}
+		p.emit(eventC, &RedisRdbEvent{
+			EventType: EventTypeResizeDb,
+			Event:     e,
+		})
+		return false, nil
+	case opCodeAux:
+		// AUX: generic string-string fields. Use to add state to RDB
+		// which is backward compatible. Implementations of RDB loading
+		// are required to skip AUX fields they don't understand.
+		//
+		// An AUX field is composed of two strings: key and value.
+		e, err := p.parseAuxiliaryFields()
+		if err != nil {
+			return false, err
+		}
+		p.emit(eventC, &RedisRdbEvent{
+			EventType: EventTypeAuxField,
+			Event:     e,
+		})
+		if e.Filed == "lua" {
+			p.emit(eventC, &RedisRdbEvent{
+				EventType: EventTypeLuaScript,
+				Event:     newLuaScriptEvent(e.Value),
+			})
+		}
+		return false, nil
+	case opCodeModuleAux:
+		// Load module data that is not related to the Redis key space.
+		// Such data can be potentially be stored both before and after the
+		// RDB keys-values section.
+		// TODO
+		return false, nil
+	case opcodeFunction:
+		return false, fmt.Errorf("pre-release function format not supported")
+	case opCodeFunction2:
+		// TODO
+		return false, fmt.Errorf("function not suuported")
+	}
+
+	// Load object key.
+	key, err := p.parseKey()
+	if err != nil {
+		return false, err
+	}
+	p.key = key
+	// Load object value.
+	e, err := p.parseEntry(rdbType, key, p.db, p.expireAt)
+	if err != nil {
+		return false, err
+	}
+
+	// Reset state.
+	p.resetKeyState()
+	p.emit(eventC, e)
+	return false, nil
+}
+
+// emit sends an event along with a checkpoint of the state after it.
+func (p *Parser) emit(eventC chan *eventWrapper, e *RedisRdbEvent) {
+	eventC <- &eventWrapper{e: e, offset: p.recordOffset, checkpoint: p.takeCheckpoint()}
+}
+
+// resetKeyState clears the state that only applies to the next key.
+func (p *Parser) resetKeyState() {
+	p.expireAt = -1
+	p.idle = -1
+	p.freq = -1
+}
+
+// parseEntry wraps parseEntryWithValueType. In recovery mode a panic caused by
+// corrupt input is turned into an error.
+func (p *Parser) parseEntry(valueType byte, key string, DbId int, expireAt int64) (e *RedisRdbEvent, err error) {
+	if p.recovery {
+		defer func() {
+			if v := recover(); v != nil {
+				e, err = nil, fmt.Errorf("corrupt value: %v", v)
+			}
+		}()
+	}
+	return p.parseEntryWithValueType(valueType, key, DbId, expireAt)
+}
+
+func (p *Parser) parseFreq() (uint8, error) {
+	return p.r.GetUint8()
 }
 
 func (p *Parser) parseIdle() (uint64, error) {
@@ -305,7 +446,7 @@ func (p *Parser) parseResizeDb() (*ResizeDbEvent, error) {
 func (p *Parser) parseExpireTime() (int64, error) {
 	b, err := p.r.ReadFixedBytes(4)
 	if err != nil {
-		return 0, nil
+		return 0, err
 	}
 	expireAt := binary.LittleEndian.Uint32(b)
 	return int64(expireAt) * 1000, nil
@@ -314,7 +455,7 @@ func (p *Parser) parseExpireTime() (int64, error) {
 func (p *Parser) parseExpireTimeMs() (int64, error) {
 	b, err := p.r.ReadFixedBytes(8)
 	if err != nil {
-		return 0, nil
+		return 0, err
 	}
 	expireAt := binary.LittleEndian.Uint64(b)
 	return int64(expireAt), nil
@@ -2,6 +2,7 @@ package rdb
 
 import (
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 	"math"
@@ -10,8 +11,25 @@ import (
 
 type rdbReader struct {
 	r io.Reader
+
+	// Number of bytes consumed from the start of r.
+	offset int64
+
+	// Bytes consumed since the last mark, at buf[start:], kept while
+	// recording so that the reader can be rewound. Bytes in buf[pos:] are
+	// replayed before reading from r again. The whole record being parsed is
+	// kept, so memory grows with the largest record.
+	recording bool
+	buf       []byte
+	start     int
+	pos       int
+
+	// If positive, reads past this offset fail with errReadLimit.
+	limit int64
 }
 
+var errReadLimit = errors.New("read limit exceeded")
+
 func newRdbReader(r io.Reader) *rdbReader {
 	return &rdbReader{r: r}
 }
@@ -118,7 +136,8 @@ func (r *rdbReader) GetLengthString() (string, error) {
 	case lengthEncodingLength:
 		return r.ReadFixedString(int(n))
 	case lengthEncodingInteger:
-		return strconv.Itoa(int(n)), nil
+		// Integers are signed, see rdb.c::rdbLoadIntegerObject.
+		return strconv.FormatInt(int64(n), 10), nil
 	default:
 		return "", fmt.Errorf("unsupported encoding %d for GetLengthString", encoding)
 	}
@@ -172,13 +191,105 @@ func (r *rdbReader) ReadByte() (byte, error) {
 }
 
 func (r *rdbReader) Read(p []byte) (n int, err error) {
-	return r.r.Read(p)
+	if r.limit > 0 {
+		if r.offset >= r.limit {
+			return 0, errReadLimit
+		}
+		if remain := r.limit - r.offset; int64(len(p)) > remain {
+			p = p[:remain]
+		}
+	}
+	if r.pos < len(r.buf) {
+		n = copy(p, r.buf[r.pos:])
+		r.pos += n
+	} else {
+		if !r.recording && r.buf != nil {
+			r.buf = nil
+			r.start = 0
+			r.pos = 0
+		}
+		n, err = r.r.Read(p)
+		if r.recording {
+			r.buf = append(r.buf, p[:n]...)
+			r.pos = len(r.buf)
+		}
+	}
+	r.offset += int64(n)
+	return n, err
 }
 
+// readChunkSize bounds the up-front allocation of ReadFixedBytes, so that a
+// bogus length read from a corrupt file fails at EOF instead of allocating
+// gigabytes first.
+const readChunkSize = 1 << 20
+
 func (r *rdbReader) ReadFixedBytes(size int) ([]byte, error) {
-	bs := make([]byte, size)
-	_, err := io.ReadFull(r.r, bs)
-	return bs, err
+	if size < 0 {
+		return nil, fmt.Errorf("invalid length: %d", size)
+	}
+	if r.limit > 0 && r.offset+int64(size) > r.limit {
+		return nil, errReadLimit
+	}
+	if size <= readChunkSize {
+		bs := make([]byte, size)
+		_, err := io.ReadFull(r, bs)
+		return bs, err
+	}
+
+	bs := make([]byte, 0, readChunkSize)
+	for len(bs) < size {
+		n := size - len(bs)
+		if n > readChunkSize {
+			n = readChunkSize
+		}
+		chunk := make([]byte, n)
+		if _, err := io.ReadFull(r, chunk); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		bs = append(bs, chunk...)
+	}
+	return bs, nil
+}
+
+// allocHint caps a slice capacity taken from the input, so that a corrupt
+// length can't trigger a huge allocation before any element is read.
+func allocHint(n int) int {
+	if n > 1024 {
+		return 1024
+	}
+	return n
+}
+
+// Offset returns the number of bytes consumed so far.
+func (r *rdbReader) Offset() int64 {
+	return r.offset
+}
+
+// mark starts recording at the current position, discarding anything
+// recorded before it.
+func (r *rdbReader) mark() {
+	// Drop the bytes before the mark once they are most of buf, so that
+	// marking byte by byte does not copy buf every time.
+	if r.pos > len(r.buf)/2 {
+		r.buf = append(r.buf[:0], r.buf[r.pos:]...)
+		r.pos = 0
+	}
+	r.start = r.pos
+	r.recording = true
+}
+
+// rewind moves the reader back to the last mark.
+func (r *rdbReader) rewind() {
+	r.offset -= int64(r.pos - r.start)
+	r.pos = r.start
+}
+
+// unmark stops recording. Bytes not consumed yet are still replayed.
+func (r *rdbReader) unmark() {
+	r.recording = false
 }
 
 func (r *rdbReader) ReadFixedString(size int) (string, error) {
@@ -229,24 +340,24 @@ func (r *rdbReader) GetEncodingLength() (lengthEncoding, uint64, error) {
 			if err != nil {
 				return 0, 0, err
 			}
-			return lengthEncodingInteger, uint64(b2), nil
+			return lengthEncodingInteger, uint64(int8(b2)), nil
 		case 1:
 			b2, err := r.ReadFixedBytes(2)
 			if err != nil {
 				return 0, 0, err
 			}
-			v := binary.LittleEndian.Uint16(b2)
+			v := int16(binary.LittleEndian.Uint16(b2))
 			return lengthEncodingInteger, uint64(v), nil
 		case 2:
 			b2, err := r.ReadFixedBytes(4)
 			if err != nil {
 				return 0, 0, err
 			}
-			v := binary.LittleEndian.Uint32(b2)
+			v := int32(binary.LittleEndian.Uint32(b2))
 			return lengthEncodingInteger, uint64(v), nil
 		case 3:
 			// TODO comparessed
-			panic("compressed")
+			return 0, 0, fmt.Errorf("unsupported compressed string")
 		default:
 			return 0, 0, fmt.Errorf("unsupported 6 bits: %x", b&0x3F)
 		}
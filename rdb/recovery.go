@@ -0,0 +1,183 @@
+package rdb
+
+import (
+	"fmt"
+	"io"
+)
+
+const (
+	// Maximum number of bytes a single probe may read while looking for the
+	// next record. Records larger than this can't be used to resynchronise.
+	probeWindow = 8 << 20
+
+	// Maximum number of opcodes accepted before a key is expected.
+	probeMaxOpcodes = 8
+)
+
+// CorruptEntryEvent is reported in recovery mode for a record that could not
+// be decoded.
+type CorruptEntryEvent struct {
+	// Offset of the first byte of the corrupt record.
+	Offset int64
+
+	Db int
+
+	// Key of the record, empty if the key itself could not be read.
+	Key string
+
+	Err error
+
+	// Number of bytes skipped to reach the next plausible record.
+	SkippedBytes int64
+}
+
+func (e *CorruptEntryEvent) Debug() {
+	fmt.Printf("=== CorruptEntryEvent ===\n")
+	fmt.Printf("Offset: %d\n", e.Offset)
+	fmt.Printf("DbId: %d\n", e.Db)
+	fmt.Printf("Key: %s\n", e.Key)
+	fmt.Printf("Error: %s\n", e.Err)
+	fmt.Printf("Skipped bytes: %d\n", e.SkippedBytes)
+	fmt.Printf("\n")
+}
+
+// RecoverySummaryEvent is reported in recovery mode once the end of the file
+// is reached.
+type RecoverySummaryEvent struct {
+	CorruptEntries int
+	SkippedBytes   int64
+
+	// Whether the file ended before the EOF opcode.
+	Truncated bool
+}
+
+func (e *RecoverySummaryEvent) Debug() {
+	fmt.Printf("=== RecoverySummaryEvent ===\n")
+	fmt.Printf("Corrupt entries: %d\n", e.CorruptEntries)
+	fmt.Printf("Skipped bytes: %d\n", e.SkippedBytes)
+	fmt.Printf("Truncated: %t\n", e.Truncated)
+	fmt.Printf("\n")
+}
+
+// resync is called with the reader rewound to the start of a corrupt record.
+// It skips bytes until a plausible record starts, leaving the reader there,
+// and returns the number of skipped bytes. io.EOF is returned if no record is
+// found before the end of the file.
+func (p *Parser) resync() (int64, error) {
+	if _, err := p.r.ReadByte(); err != nil {
+		return 0, err
+	}
+	skipped := int64(1)
+	for {
+		p.r.mark()
+		b, err := p.r.ReadByte()
+		if err != nil {
+			return skipped, err
+		}
+		// Only probe the bytes a record can start with, a probe may read
+		// up to probeWindow bytes.
+		if isOpCode(b) || isValueType(b) {
+			p.r.rewind()
+			p.r.limit = p.r.Offset() + probeWindow
+			ok := p.probe()
+			p.r.limit = 0
+			p.r.rewind()
+			if ok {
+				return skipped, nil
+			}
+			if _, err := p.r.ReadByte(); err != nil {
+				return skipped, err
+			}
+		}
+		skipped++
+	}
+}
+
+// probe reports whether the bytes at the current position decode as a
+// sequence of opcodes followed by a key-value pair, which is itself followed by
+// a valid opcode or value type, or as the EOF opcode and checksum ending the
+// file.
+func (p *Parser) probe() bool {
+	for i := 0; i < probeMaxOpcodes; i++ {
+		b, err := p.r.ReadByte()
+		if err != nil {
+			return false
+		}
+
+		switch b {
+		case opExpireTime:
+			_, err = p.parseExpireTime()
+		case opExpireTimeMs:
+			_, err = p.parseExpireTimeMs()
+		case opCodeFreq:
+			_, err = p.parseFreq()
+		case opCodeIdle:
+			_, err = p.parseIdle()
+		case opCodeSelectDb:
+			_, err = p.parseSelectDb()
+		case opCodeResizeDb:
+			_, err = p.parseResizeDb()
+		case opCodeAux:
+			_, err = p.parseAuxiliaryFields()
+		case opCodeEOF:
+			return p.probeEOF()
+		default:
+			if !isValueType(b) {
+				return false
+			}
+			key, err := p.parseKey()
+			if err != nil {
+				return false
+			}
+			if _, err := p.parseEntry(b, key, 0, -1); err != nil {
+				return false
+			}
+			next, err := p.r.ReadByte()
+			if err == io.EOF {
+				// Truncated right after this entry.
+				return true
+			}
+			return err == nil && (isOpCode(next) || isValueType(next))
+		}
+		if err != nil {
+			return false
+		}
+	}
+	return false
+}
+
+func (p *Parser) probeEOF() bool {
+	if p.version >= 5 {
+		if _, err := p.r.ReadFixedBytes(8); err != nil {
+			return false
+		}
+	}
+	_, err := p.r.ReadByte()
+	return err == io.EOF
+}
+
+func isOpCode(b byte) bool {
+	switch b {
+	case opCodeModuleAux, opCodeIdle, opCodeFreq, opCodeAux, opCodeResizeDb,
+		opExpireTimeMs, opExpireTime, opCodeSelectDb, opCodeEOF:
+		return true
+	default:
+		return false
+	}
+}
+
+// isValueType reports whether b is a value type supported by
+// parseEntryWithValueType.
+func isValueType(b byte) bool {
+	switch b {
+	case rdbTypeString,
+		rdbTypeList, rdbTypeZipList, rdbTypeListQuickList, rdbTypeListQuickList2,
+		rdbTypeSet, rdbTypeSetListPack, rdbTypeIntSet,
+		rdbTypeZSetZipList, rdbTypeZSetListPack, rdbTypeZSet, rdbTypeZSet2,
+		rdbTypeHashZipList, rdbTypeHashListPack, rdbTypeHash,
+		rdbTypeStreamListPacks, rdbTypeStreamListPacks2:
+		return true
+	default:
+		return false
+	}
+}
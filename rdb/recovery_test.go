@@ -0,0 +1,131 @@
+package rdb
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func collectEvents(t *testing.T, p *Parser) []*RedisRdbEvent {
+	t.Helper()
+	s, err := p.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var events []*RedisRdbEvent
+	for s.HasNext() {
+		events = append(events, s.Next())
+	}
+	if err := s.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return events
+}
+
+func stringKeys(events []*RedisRdbEvent) []string {
+	var keys []string
+	for _, e := range events {
+		if e.EventType == EventTypeStringObject {
+			keys = append(keys, e.Event.(*StringObjectEvent).Key)
+		}
+	}
+	return keys
+}
+
+func TestParser_RecoveryCorruptEntry(t *testing.T) {
+	b := newRdbBuilder("0009")
+	b.selectDb(0)
+	b.stringObject("a", "1")
+	corruptOffset := b.Len()
+	b.setObject("broken", "x", "y")
+	b.stringObject("b", "2")
+	b.eof()
+
+	data := b.Bytes()
+	// Claim 60 set members while only two follow.
+	data[corruptOffset+len("broken")+2] = 60
+
+	p, err := NewReaderParser(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := p.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for s.HasNext() {
+	}
+	assert.Error(t, s.Err())
+
+	p, err = NewReaderParser(bytes.NewReader(data), WithRecovery())
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := collectEvents(t, p)
+	assert.Equal(t, []string{"a", "b"}, stringKeys(events))
+
+	var corrupt *CorruptEntryEvent
+	var summary *RecoverySummaryEvent
+	for _, e := range events {
+		switch e.EventType {
+		case EventTypeCorruptEntry:
+			corrupt = e.Event.(*CorruptEntryEvent)
+		case EventTypeRecoverySummary:
+			summary = e.Event.(*RecoverySummaryEvent)
+		}
+	}
+	if assert.NotNil(t, corrupt) {
+		assert.Equal(t, int64(corruptOffset), corrupt.Offset)
+		assert.Equal(t, "broken", corrupt.Key)
+		assert.Equal(t, int64(1+1+len("broken")+1+2+2), corrupt.SkippedBytes)
+	}
+	if assert.NotNil(t, summary) {
+		assert.Equal(t, 1, summary.CorruptEntries)
+		assert.Equal(t, corrupt.SkippedBytes, summary.SkippedBytes)
+		assert.False(t, summary.Truncated)
+	}
+}
+
+func TestParser_RecoveryTruncated(t *testing.T) {
+	b := newRdbBuilder("0009")
+	b.selectDb(0)
+	b.stringObject("a", "1")
+	b.stringObject("b", "2")
+	b.stringObject("c", "long value")
+	data := b.Bytes()[:b.Len()-4]
+
+	p, err := NewReaderParser(bytes.NewReader(data), WithRecovery())
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := collectEvents(t, p)
+	assert.Equal(t, []string{"a", "b"}, stringKeys(events))
+
+	last := events[len(events)-1]
+	if assert.Equal(t, EventTypeRecoverySummary, last.EventType) {
+		summary := last.Event.(*RecoverySummaryEvent)
+		assert.True(t, summary.Truncated)
+		assert.Equal(t, int64(1+2+1+6), summary.SkippedBytes)
+	}
+}
+
+func TestParser_RecoveryLargeCorruptRegion(t *testing.T) {
+	b := newRdbBuilder("0009")
+	b.selectDb(0)
+	b.stringObject("a", "1")
+	// A string longer than the rest of the file, the parser reads to the
+	// end before going back to scan the junk byte by byte.
+	b.WriteByte(rdbTypeString)
+	b.str("b")
+	b.length(8 << 20)
+	b.Write(bytes.Repeat([]byte{'x'}, 1<<20))
+	b.stringObject("c", "3")
+	b.eof()
+
+	p, err := NewReaderParser(bytes.NewReader(b.Bytes()), WithRecovery())
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := collectEvents(t, p)
+	assert.Equal(t, []string{"a", "c"}, stringKeys(events))
+}
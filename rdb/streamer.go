@@ -1,18 +1,32 @@
 package rdb
 
 type EventStreamer struct {
-	c   chan *eventWrapper
-	o   *RedisRdbEvent
-	err error
+	c          chan *eventWrapper
+	o          *RedisRdbEvent
+	offset     int64
+	checkpoint Checkpoint
+	metadata   RdbMetadata
+	err        error
 }
 
 type eventWrapper struct {
 	e   *RedisRdbEvent
 	err error
+
+	// Offset of the record the event was parsed from, and the parser state
+	// right after it.
+	offset     int64
+	checkpoint Checkpoint
 }
 
 func newEventStreamer(c chan *eventWrapper) *EventStreamer {
-	return &EventStreamer{c: c}
+	return &EventStreamer{
+		c: c,
+		metadata: RdbMetadata{
+			ReplStreamDb: -1,
+			Aux:          make(map[string]string),
+		},
+	}
 }
 
 func (s *EventStreamer) HasNext() bool {
@@ -29,6 +43,9 @@ func (s *EventStreamer) HasNext() bool {
 		return false
 	}
 	s.o = w.e
+	s.metadata.update(w.e)
+	s.offset = w.offset
+	s.checkpoint = w.checkpoint
 	return true
 }
 
@@ -36,6 +53,19 @@ func (s *EventStreamer) Next() *RedisRdbEvent {
 	return s.o
 }
 
+// Checkpoint returns the parser state right after the current event.
+// Pass it to NewParserAt to continue parsing with the next event.
+func (s *EventStreamer) Checkpoint() Checkpoint {
+	return s.checkpoint
+}
+
+// Metadata returns the metadata collected from the events so far. It is
+// complete once the first database is selected, since Redis writes all
+// auxiliary fields before the key space.
+func (s *EventStreamer) Metadata() *RdbMetadata {
+	return &s.metadata
+}
+
 func (s *EventStreamer) Err() error {
 	return s.err
 }
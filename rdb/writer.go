@@ -0,0 +1,161 @@
+package rdb
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc64"
+	"io"
+	"math"
+)
+
+// Version of the RDB files written by Writer. It only uses encodings that
+// Redis 5.0 and later load.
+const writerVersion = 9
+
+// Reflected polynomial of the CRC-64-Jones checksum of RDB files.
+// crc64.c
+var crcTable = crc64.MakeTable(0x95ac9329ac4bc9b5)
+
+// Writer writes an RDB file. Values are written with the plain encodings
+// rather than the compact ones, Redis converts them when loading.
+type Writer struct {
+	w io.Writer
+
+	// Checksum, inverted as hash/crc64 keeps it.
+	crc uint64
+
+	err error
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{
+		w: w,
+		// Redis starts from 0 and does not invert the result.
+		crc: ^uint64(0),
+	}
+}
+
+// WriteHeader writes the magic number and version, it must be called first.
+func (w *Writer) WriteHeader() error {
+	w.write([]byte(fmt.Sprintf("REDIS%04d", writerVersion)))
+	return w.err
+}
+
+func (w *Writer) WriteAux(key, value string) error {
+	w.writeByte(opCodeAux)
+	w.writeString(key)
+	w.writeString(value)
+	return w.err
+}
+
+func (w *Writer) SelectDb(db int) error {
+	w.writeByte(opCodeSelectDb)
+	w.writeLength(uint64(db))
+	return w.err
+}
+
+// WriteString writes a string key. expireAt is in milliseconds since the
+// epoch, -1 if the key does not expire.
+func (w *Writer) WriteString(key string, value string, expireAt int64) error {
+	w.writeKey(rdbTypeString, key, expireAt)
+	w.writeString(value)
+	return w.err
+}
+
+func (w *Writer) WriteList(key string, elements []string, expireAt int64) error {
+	w.writeKey(rdbTypeList, key, expireAt)
+	w.writeLength(uint64(len(elements)))
+	for _, e := range elements {
+		w.writeString(e)
+	}
+	return w.err
+}
+
+func (w *Writer) WriteSet(key string, members []string, expireAt int64) error {
+	w.writeKey(rdbTypeSet, key, expireAt)
+	w.writeLength(uint64(len(members)))
+	for _, m := range members {
+		w.writeString(m)
+	}
+	return w.err
+}
+
+func (w *Writer) WriteZSet(key string, members []ZSetMember, expireAt int64) error {
+	w.writeKey(rdbTypeZSet2, key, expireAt)
+	w.writeLength(uint64(len(members)))
+	for _, m := range members {
+		w.writeString(m.Value)
+		var b [8]byte
+		binary.LittleEndian.PutUint64(b[:], math.Float64bits(m.Score))
+		w.write(b[:])
+	}
+	return w.err
+}
+
+func (w *Writer) WriteHash(key string, fields []HashField, expireAt int64) error {
+	w.writeKey(rdbTypeHash, key, expireAt)
+	w.writeLength(uint64(len(fields)))
+	for _, f := range fields {
+		w.writeString(f.Field)
+		w.writeString(f.Value)
+	}
+	return w.err
+}
+
+// Close writes the EOF opcode and the checksum. It does not close the
+// underlying writer.
+func (w *Writer) Close() error {
+	w.writeByte(opCodeEOF)
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], ^w.crc)
+	w.write(b[:])
+	return w.err
+}
+
+func (w *Writer) writeKey(valueType byte, key string, expireAt int64) {
+	if expireAt != -1 {
+		w.writeByte(opExpireTimeMs)
+		var b [8]byte
+		binary.LittleEndian.PutUint64(b[:], uint64(expireAt))
+		w.write(b[:])
+	}
+	w.writeByte(valueType)
+	w.writeString(key)
+}
+
+func (w *Writer) writeString(s string) {
+	w.writeLength(uint64(len(s)))
+	w.write([]byte(s))
+}
+
+// rdb.c::rdbSaveLen
+func (w *Writer) writeLength(n uint64) {
+	switch {
+	case n < 1<<6:
+		w.writeByte(byte(n))
+	case n < 1<<14:
+		w.write([]byte{byte(n>>8) | 0x40, byte(n)})
+	case n <= math.MaxUint32:
+		var b [5]byte
+		b[0] = 0x80
+		binary.BigEndian.PutUint32(b[1:], uint32(n))
+		w.write(b[:])
+	default:
+		var b [9]byte
+		b[0] = 0x81
+		binary.BigEndian.PutUint64(b[1:], n)
+		w.write(b[:])
+	}
+}
+
+func (w *Writer) writeByte(b byte) {
+	w.write([]byte{b})
+}
+
+func (w *Writer) write(b []byte) {
+	if w.err != nil {
+		return
+	}
+	w.crc = crc64.Update(w.crc, crcTable, b)
+	_, w.err = w.w.Write(b)
+}
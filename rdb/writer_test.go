@@ -0,0 +1,77 @@
+package rdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	assert.NoError(t, w.WriteHeader())
+	assert.NoError(t, w.WriteAux("redis-ver", "7.2.0"))
+	assert.NoError(t, w.SelectDb(0))
+	assert.NoError(t, w.WriteString("s", strings.Repeat("v", 20000), 1700000000000))
+	assert.NoError(t, w.WriteList("l", []string{"a", "b"}, -1))
+	assert.NoError(t, w.SelectDb(3))
+	assert.NoError(t, w.WriteSet("set", []string{"x"}, -1))
+	assert.NoError(t, w.WriteZSet("z", []ZSetMember{{Value: "m", Score: 1.5}}, -1))
+	assert.NoError(t, w.WriteHash("h", []HashField{{Field: "f", Value: "v"}}, -1))
+	assert.NoError(t, w.Close())
+
+	p, err := NewReaderParser(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := collectEvents(t, p)
+
+	var got []Event
+	for _, e := range events {
+		switch e.EventType {
+		case EventTypeVersion, EventTypeAuxField, EventTypeStringObject, EventTypeListObject,
+			EventTypeSetObject, EventTypeZSetObject, EventTypeHashObject:
+			got = append(got, e.Event)
+		}
+	}
+	if !assert.Len(t, got, 7) {
+		return
+	}
+	assert.Equal(t, &VersionEvent{Version: 9}, got[0])
+	assert.Equal(t, &AuxFieldEvent{Filed: "redis-ver", Value: "7.2.0"}, got[1])
+	s := got[2].(*StringObjectEvent)
+	assert.Equal(t, 20000, len(s.Value))
+	assert.Equal(t, int64(1700000000000), s.ExpireAt())
+	assert.Equal(t, []string{"a", "b"}, got[3].(*ListObjectEvent).Elements)
+	assert.Equal(t, int64(-1), got[3].(*ListObjectEvent).ExpireAt())
+	assert.Equal(t, 3, got[4].(*SetObjectEvent).DbId)
+	assert.Equal(t, []ZSetMember{{Value: "m", Score: 1.5}}, got[5].(*ZSetObjectEvent).Members)
+	assert.Equal(t, []HashField{{Field: "f", Value: "v"}}, got[6].(*HashObjectEvent).Fields)
+
+	// Checksum of everything before it.
+	data := buf.Bytes()
+	assert.Equal(t, redisCrc64(data[:len(data)-8]), binary.LittleEndian.Uint64(data[len(data)-8:]))
+}
+
+// redisCrc64 is the bitwise CRC-64-Jones of crc64.c.
+func redisCrc64(data []byte) uint64 {
+	var crc uint64
+	for _, b := range data {
+		crc ^= uint64(b)
+		for i := 0; i < 8; i++ {
+			if crc&1 == 1 {
+				crc = crc>>1 ^ 0x95ac9329ac4bc9b5
+			} else {
+				crc >>= 1
+			}
+		}
+	}
+	return crc
+}
+
+func TestRedisCrc64(t *testing.T) {
+	// crc64.c test vector.
+	assert.Equal(t, uint64(0xe9c6d914c4b8d9ca), redisCrc64([]byte("123456789")))
+}
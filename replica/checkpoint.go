@@ -0,0 +1,94 @@
+package replica
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// Checkpoint is the position in the replication stream to continue from
+// after a restart.
+type Checkpoint struct {
+	ReplicaId string `json:"replid"`
+
+	// Offset processed, see Replica.ProcessedOffset.
+	Offset int64 `json:"offset"`
+
+	// Database selected at Offset, which the master does not select again
+	// in a partial synchronization.
+	Db int `json:"db"`
+}
+
+// CheckpointStore persists the checkpoint of a replica, see
+// Config.CheckpointStore.
+type CheckpointStore interface {
+	// Load returns the saved checkpoint, or nil if there is none.
+	Load() (*Checkpoint, error)
+
+	Save(cp *Checkpoint) error
+}
+
+// FileCheckpointStore saves the checkpoint in a JSON file. The file is
+// replaced with a rename, so that a crash never leaves it half written.
+type FileCheckpointStore struct {
+	name string
+}
+
+func NewFileCheckpointStore(name string) *FileCheckpointStore {
+	return &FileCheckpointStore{name: name}
+}
+
+func (s *FileCheckpointStore) Load() (*Checkpoint, error) {
+	data, err := os.ReadFile(s.name)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	cp := &Checkpoint{}
+	if err := json.Unmarshal(data, cp); err != nil {
+		return nil, err
+	}
+	return cp, nil
+}
+
+func (s *FileCheckpointStore) Save(cp *Checkpoint) error {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(s.name)
+	f, err := os.CreateTemp(dir, filepath.Base(s.name)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err := os.Rename(f.Name(), s.name); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	// Persist the rename. Not every platform can sync a directory, the
+	// file itself is complete either way.
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package replica
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileCheckpointStore(t *testing.T) {
+	dir := t.TempDir()
+	s := NewFileCheckpointStore(filepath.Join(dir, "checkpoint.json"))
+
+	cp, err := s.Load()
+	assert.NoError(t, err)
+	assert.Nil(t, cp)
+
+	assert.NoError(t, s.Save(&Checkpoint{ReplicaId: "a", Offset: 100, Db: 1}))
+	assert.NoError(t, s.Save(&Checkpoint{ReplicaId: "a", Offset: 200, Db: 2}))
+	cp, err = s.Load()
+	assert.NoError(t, err)
+	assert.Equal(t, &Checkpoint{ReplicaId: "a", Offset: 200, Db: 2}, cp)
+
+	// No temporary file is left behind.
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, entries, 1)
+}
@@ -0,0 +1,41 @@
+package replica
+
+import "time"
+
+type EventType uint8
+
+const (
+	// EventTypeConnected is sent once the master accepted the connection,
+	// before the synchronization is requested.
+	EventTypeConnected EventType = iota
+
+	// EventTypePartialResync is sent when the master continues the
+	// replication from the requested offset (+CONTINUE). ReplicaId is the
+	// replication id of the master, which changes after a failover.
+	EventTypePartialResync
+
+	// EventTypeFullResync is sent when the master starts a full
+	// synchronization (+FULLRESYNC), before the RDB is written to RdbWriter.
+	// After a reconnection, the data received before must be discarded.
+	EventTypeFullResync
+
+	// EventTypeDisconnected is sent when the synchronization ends with an
+	// error, either losing the connection or failing to establish it.
+	EventTypeDisconnected
+)
+
+// Event is a change of the connection with the master, see
+// Config.EventHandler.
+type Event struct {
+	Type EventType
+
+	// Replication id and offset of the master the replication starts from,
+	// for EventTypePartialResync and EventTypeFullResync.
+	ReplicaId string
+	Offset    int64
+
+	// Error ending the synchronization and, with Config.Reconnect, the time
+	// before reconnecting, for EventTypeDisconnected.
+	Err   error
+	Delay time.Duration
+}
@@ -0,0 +1,169 @@
+package replica
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/vczyh/redis-lib/resp"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testRdb is a minimal RDB holding the string key "a" in database 0.
+const testRdb = "REDIS0009" + "\xfe\x00" + "\x00\x01a\x011" + "\xff" + "\x00\x00\x00\x00\x00\x00\x00\x00"
+
+// fakeMaster is a master accepting replicas on a local port. It answers the
+// handshake, sends the RDB and then the replication stream, or continues the
+// replication stream from the offset of PSYNC.
+type fakeMaster struct {
+	t  *testing.T
+	ln net.Listener
+
+	replId string
+	offset int64
+	rdb    string
+
+	// Bytes sent after the RDB.
+	stream string
+
+	// Replication id sent with +CONTINUE, empty to keep replId.
+	continueId string
+
+	// Close the first connection after sending this many bytes of the
+	// stream, 0 to send it all.
+	dropAt int
+
+	// Answer every PSYNC with FULLRESYNC, and wait rdbDelay before sending
+	// the RDB after the first connection.
+	noBacklog bool
+	rdbDelay  time.Duration
+
+	// Arguments of the PSYNC commands received.
+	psyncs chan []string
+
+	// Commands sent by the replica after the handshake, e.g. REPLCONF ACK,
+	// and while the RDB is delayed.
+	received  chan []string
+	beforeRdb chan []string
+}
+
+func newFakeMaster(t *testing.T, stream string) *fakeMaster {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &fakeMaster{
+		t:         t,
+		ln:        ln,
+		replId:    strings.Repeat("a", 40),
+		offset:    100,
+		rdb:       testRdb,
+		stream:    stream,
+		psyncs:    make(chan []string, 100),
+		received:  make(chan []string, 100),
+		beforeRdb: make(chan []string, 100),
+	}
+	t.Cleanup(func() {
+		ln.Close()
+	})
+	return m
+}
+
+// start accepts replicas once the fake master is set up.
+func (m *fakeMaster) start() *fakeMaster {
+	go m.serve()
+	return m
+}
+
+func (m *fakeMaster) port() int {
+	return m.ln.Addr().(*net.TCPAddr).Port
+}
+
+func (m *fakeMaster) serve() {
+	for first := true; ; first = false {
+		nc, err := m.ln.Accept()
+		if err != nil {
+			return
+		}
+		m.handle(nc, first)
+	}
+}
+
+func (m *fakeMaster) handle(nc net.Conn, first bool) {
+	defer nc.Close()
+
+	cr := resp.NewCommandReader(bufio.NewReader(nc))
+	for {
+		args, err := cr.ReadCommand()
+		if err != nil {
+			return
+		}
+		switch strings.ToUpper(string(args[0])) {
+		case "PING":
+			fmt.Fprint(nc, "+PONG\r\n")
+		case "PSYNC":
+			m.psyncs <- []string{string(args[1]), string(args[2])}
+			stream := m.psync(nc, cr, first, string(args[1]), string(args[2]))
+			if first && m.dropAt > 0 {
+				fmt.Fprint(nc, stream[:m.dropAt])
+				return
+			}
+			fmt.Fprint(nc, stream)
+			m.readCommands(cr, m.received)
+			return
+		default:
+			fmt.Fprint(nc, "+OK\r\n")
+		}
+	}
+}
+
+// psync answers PSYNC and returns the part of the stream to send.
+func (m *fakeMaster) psync(nc net.Conn, cr *resp.CommandReader, first bool, replId, offset string) string {
+	n, err := strconv.ParseInt(offset, 10, 64)
+	backlog := n - 1 - m.offset
+	if err == nil && !m.noBacklog && replId == m.replId && backlog >= 0 && backlog <= int64(len(m.stream)) {
+		if m.continueId != "" {
+			fmt.Fprintf(nc, "+CONTINUE %s\r\n", m.continueId)
+			m.replId = m.continueId
+		} else {
+			fmt.Fprint(nc, "+CONTINUE\r\n")
+		}
+		return m.stream[backlog:]
+	}
+
+	fmt.Fprintf(nc, "+FULLRESYNC %s %d\r\n", m.replId, m.offset)
+	if !first && m.rdbDelay > 0 {
+		nc.SetReadDeadline(time.Now().Add(m.rdbDelay))
+		m.readCommands(cr, m.beforeRdb)
+		nc.SetReadDeadline(time.Time{})
+	}
+	fmt.Fprintf(nc, "$%d\r\n%s", len(m.rdb), m.rdb)
+	return m.stream
+}
+
+// readCommands passes the commands of the replica to c until the connection
+// fails.
+func (m *fakeMaster) readCommands(cr *resp.CommandReader, c chan<- []string) {
+	for {
+		args, err := cr.ReadCommand()
+		if err != nil {
+			return
+		}
+		var command []string
+		for _, arg := range args {
+			command = append(command, string(arg))
+		}
+		c <- command
+	}
+}
+
+func command(args ...string) string {
+	s := "*" + strconv.Itoa(len(args)) + "\r\n"
+	for _, arg := range args {
+		s += "$" + strconv.Itoa(len(arg)) + "\r\n" + arg + "\r\n"
+	}
+	return s
+}
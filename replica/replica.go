@@ -1,23 +1,99 @@
 package replica
 
 import (
+	"bufio"
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"github.com/vczyh/redis-lib/client"
+	"github.com/vczyh/redis-lib/connection"
+	"github.com/vczyh/redis-lib/resp"
 	"io"
 	"math"
+	"os"
 	"strconv"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 )
 
+var (
+	// ErrFullResyncRequired is returned when the master refuses to continue
+	// the replication from the given offset and ContinueIfPartialFailed is
+	// not set.
+	ErrFullResyncRequired = errors.New("master requires a full synchronization")
+
+	// ErrClosed is returned when the replica is closed while connecting.
+	ErrClosed = errors.New("replica closed")
+)
+
+const (
+	defaultReconnectMinDelay = 1 * time.Second
+	defaultReconnectMaxDelay = 30 * time.Second
+
+	defaultCheckpointInterval = 1 * time.Second
+
+	// Time allowed to send the last ACK when the synchronization ends.
+	finalAckTimeout = 1 * time.Second
+)
+
 type Replica struct {
 	config *Config
+
+	// Guards client, which is replaced on every connection.
+	mu     sync.Mutex
 	client *client.Client
 
+	closed    chan struct{}
+	closeOnce sync.Once
+
+	// Replication id of the master, written under mu by the synchronization
+	// and read by ReplicaId.
 	replicaId     string
 	replicaOffset atomic.Int64
+
+	// Offset committed by the application with ManualCommit, -1 until the
+	// RDB of a full synchronization is committed, and the end of the last
+	// data command passed to it.
+	commitOffset   atomic.Int64
+	lastDataOffset atomic.Int64
+
+	// Serializes the commands written to the master.
+	writeMu sync.Mutex
+
+	// Database selected by the replication stream.
+	db int
+
+	// Database at the committed offset, and the SELECT of the stream after
+	// it with ManualCommit, see dbAt.
+	committedDb int
+	selects     []dbChange
+
+	// Last checkpoint saved to CheckpointStore.
+	checkpoint     Checkpoint
+	checkpointTime time.Time
+}
+
+type dbChange struct {
+	// Offset right after the SELECT.
+	offset int64
+	db     int
+}
+
+// ReplicationCommand is a command of the replication stream.
+type ReplicationCommand struct {
+	// Database the command applies to, selected by an earlier SELECT.
+	Db int
+
+	Args [][]byte
+
+	// Replication offsets of the first byte of the command and right after
+	// it. EndOffset is the offset to acknowledge once the command is
+	// processed.
+	StartOffset int64
+	EndOffset   int64
 }
 
 type Config struct {
@@ -38,7 +114,8 @@ type Config struct {
 	// Whether to do a full synchronized after partial synchronization failed.
 	ContinueIfPartialFailed bool
 
-	// Receive RDB from master in full synchronization.
+	// Receive RDB from master in full synchronization, the RDB is discarded
+	// if nil.
 	RdbWriter io.Writer
 
 	// Whether to continue incremental synchronization(AOF) after full synchronization.
@@ -48,6 +125,56 @@ type Config struct {
 	// Receive all AOF bytes stream in partial synchronization.
 	AofWriter io.Writer
 
+	// Receive the commands of the replication stream, instead of or along
+	// with the bytes written to AofWriter. SELECT is reported as the Db of
+	// the commands following it, and the PING and REPLCONF the master sends
+	// to check the connection are reported to ControlHandler instead.
+	// Returning an error stops the synchronization.
+	CommandHandler func(cmd *ReplicationCommand) error
+
+	// Receive the PING and REPLCONF of the replication stream, after the
+	// replica answered REPLCONF GETACK. They change no key but move the
+	// offset, e.g. to measure the lag with the master. Returning an error
+	// stops the synchronization.
+	ControlHandler func(cmd *ReplicationCommand) error
+
+	// Acknowledge to the master only the offsets passed to Replica.Commit,
+	// rather than the offset of the commands read, so that the replication
+	// lag seen by the master reflects what the application processed. PING,
+	// SELECT and REPLCONF following the last committed command are
+	// acknowledged with it. The RDB of a full synchronization is committed
+	// with the Offset of EventTypeFullResync, nothing is acknowledged or
+	// saved to CheckpointStore before.
+	ManualCommit bool
+
+	// Reconnect when the connection with the master is lost, and continue
+	// with a partial synchronization (PSYNC replicaId offset+1) from the
+	// offset reached, or the committed offset with ManualCommit. Whether a
+	// full synchronization may follow is decided by ContinueIfPartialFailed.
+	// SyncWithMaster then only returns once the synchronization is over, or
+	// for the errors that reconnecting does not solve: rejected
+	// authentication, a required full synchronization, and the errors of
+	// CommandHandler, ControlHandler and of the writers.
+	Reconnect bool
+
+	// Delay before reconnecting, doubled after every attempt up to
+	// ReconnectMaxDelay. The delay starts over once a synchronization is
+	// accepted by the master. Default 1s and 30s.
+	ReconnectMinDelay time.Duration
+	ReconnectMaxDelay time.Duration
+
+	// Receive the changes of the connection with the master, see EventType.
+	EventHandler func(e *Event)
+
+	// Persist the processed replication position, to continue from it after
+	// a restart. SyncWithMaster loads the checkpoint into MasterReplicaId
+	// and MasterReplicaOffset unless MasterReplicaId is set.
+	CheckpointStore CheckpointStore
+
+	// Minimum time between two checkpoints while commands are received, the
+	// checkpoint is saved too when the synchronization ends. Default 1s.
+	CheckpointInterval time.Duration
+
 	// Whether to support diskless replication.
 	// https://redis.io/docs/management/replication/#:~:text=Normally%20a%20full,as%20intermediate%20storage.
 	// Change the default of repl-diskless-sync to yes in Redis 7.0. Replica has to wait repl-diskless-sync-delay
@@ -57,44 +184,167 @@ type Config struct {
 }
 
 func NewReplica(config *Config) (*Replica, error) {
+	// The replica keeps its own copy, config is not modified.
+	c := *config
+	if c.RdbWriter == nil {
+		c.RdbWriter = io.Discard
+	}
 	r := &Replica{
-		config: config,
+		config: &c,
+		closed: make(chan struct{}),
 	}
 	return r, nil
 }
 
 // SyncWithMaster establish a connection with the master, and sync data from master.
+// With Reconnect, the connection is established again when it is lost.
+//
+// Cancelling ctx stops the synchronization gracefully: the offset processed
+// is acknowledged to the master, the connection is closed and ctx.Err() is
+// returned.
+func (r *Replica) SyncWithMaster(ctx context.Context) error {
+	if err := r.loadCheckpoint(); err != nil {
+		return err
+	}
+
+	minDelay, maxDelay := r.config.ReconnectMinDelay, r.config.ReconnectMaxDelay
+	if minDelay <= 0 {
+		minDelay = defaultReconnectMinDelay
+	}
+	if maxDelay < minDelay {
+		maxDelay = defaultReconnectMaxDelay
+		if maxDelay < minDelay {
+			maxDelay = minDelay
+		}
+	}
+
+	delay := minDelay
+	for {
+		accepted, err := r.syncWithMaster(ctx)
+		if err == nil {
+			return nil
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			r.emit(&Event{Type: EventTypeDisconnected, Err: ctxErr})
+			return ctxErr
+		}
+		var fatal *fatalError
+		if errors.As(err, &fatal) {
+			err = fatal.err
+		}
+		if fatal != nil || !r.config.Reconnect || r.isClosed() {
+			r.emit(&Event{Type: EventTypeDisconnected, Err: err})
+			return err
+		}
+
+		if accepted {
+			delay = minDelay
+		}
+		r.emit(&Event{Type: EventTypeDisconnected, Err: err, Delay: delay})
+		t := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-r.closed:
+			t.Stop()
+			return ErrClosed
+		case <-t.C:
+		}
+		if delay *= 2; delay > maxDelay {
+			delay = maxDelay
+		}
+	}
+}
+
+// syncWithMaster syncs data from master over a new connection, accepted
+// reports whether the master accepted the PSYNC. The connection is closed
+// when it returns.
+//
 // replication.c::syncWithMaster
-func (r *Replica) SyncWithMaster() error {
+func (r *Replica) syncWithMaster(ctx context.Context) (accepted bool, err error) {
+	defer func() {
+		if !accepted {
+			return
+		}
+		if cpErr := r.saveCheckpoint(true); err == nil {
+			err = cpErr
+		}
+	}()
+
 	// Create connection with master.
-	if err := r.createClient(); err != nil {
-		return err
+	c, err := r.createClient(ctx)
+	if err != nil {
+		return false, err
 	}
+	conn := c.Conn()
+
+	// The blocking reads are interrupted with a deadline when ctx is done
+	// or the ACKs fail, the error is then returned instead of the timeout.
+	var (
+		wg     sync.WaitGroup
+		done   = make(chan struct{})
+		ackErr = make(chan error, 1)
+		acking bool
+	)
+	defer func() {
+		close(done)
+		wg.Wait()
+		if err != nil && errors.Is(err, os.ErrDeadlineExceeded) {
+			select {
+			case err = <-ackErr:
+			default:
+				if ctx.Err() != nil {
+					err = ctx.Err()
+				}
+			}
+		}
+		if acking {
+			// Acknowledge what was processed, the master may be waiting
+			// for it, e.g. with WAIT.
+			conn.SetReadDeadline(time.Time{})
+			conn.SetWriteDeadline(time.Now().Add(finalAckTimeout))
+			r.sendAck(conn)
+		}
+		r.closeClient()
+	}()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		select {
+		case <-ctx.Done():
+			conn.SetReadDeadline(time.Now())
+		case <-done:
+		}
+	}()
 
-	if err := r.client.Auth(); err != nil {
-		return err
+	if err := c.Auth(); err != nil {
+		var redisErr *resp.RedisError
+		if errors.As(err, &redisErr) {
+			return false, &fatalError{err: err}
+		}
+		return false, err
 	}
 
 	// Check for errors in the socket: after a non blocking connect() we may find that the socket is in error state.
-	if err := r.client.Ping(); err != nil {
-		return err
+	if err := c.Ping(); err != nil {
+		return false, err
 	}
 
-	conn := r.client.Conn()
 	if port := r.config.AnnouncePort; port != 0 {
 		if err := conn.WriteCommand("REPLCONF", "listening-port", strconv.Itoa(port)); err != nil {
-			return err
+			return false, err
 		}
 		if err := conn.SkipOk(); err != nil {
-			return err
+			return false, err
 		}
 	}
 	if ip := r.config.AnnounceIP; ip != "" {
 		if err := conn.WriteCommand("REPLCONF", "ip-address", ip); err != nil {
-			return err
+			return false, err
 		}
 		if err := conn.SkipOk(); err != nil {
-			return err
+			return false, err
 		}
 	}
 
@@ -111,81 +361,150 @@ func (r *Replica) SyncWithMaster() error {
 		args = append(args, "capa", "eof")
 	}
 	if err := conn.WriteCommand("REPLCONF", args...); err != nil {
-		return err
+		return false, err
 	}
 	if err := conn.SkipOk(); err != nil {
-		return err
+		return false, err
 	}
+	r.emit(&Event{Type: EventTypeConnected})
 
 	// Send PSYNC command.
 	//
-	// Full sync: PSYNC ? 01
+	// Full sync: PSYNC ? -1
 	// Partial sync: PSYNC replicaId offset
-	replicaId := "?"
-	offset := -1
-	partial := r.config.MasterReplicaId != "" && r.config.MasterReplicaOffset > 0
-	if masterReplicaId := r.config.MasterReplicaId; masterReplicaId != "" {
-		replicaId = masterReplicaId
-	}
-	if masterOffset := r.config.MasterReplicaOffset; masterOffset != 0 {
-		offset = masterOffset
+	replicaId, offset := r.psyncOffset()
+	partial := replicaId != "?" && offset > 0
+	if err := conn.WriteCommand("PSYNC", replicaId, strconv.FormatInt(offset, 10)); err != nil {
+		return false, err
 	}
-	if err := conn.WriteCommand("PSYNC", replicaId, strconv.Itoa(offset)); err != nil {
-		return err
-	}
-	reply, err := r.receiveSynchronousResponse()
+	reply, err := r.receiveSynchronousResponse(conn)
 	if err != nil {
-		return err
+		return false, err
 	}
 
-	// Write offset ack and keepalive.
-	go func() {
-		if err := r.sendOffsetAckTicker(); err != nil {
-			fmt.Printf("fail send ack to master: %s\n", err)
-			return
-		}
-	}()
+	// Write offset ack and keepalive from the start of the command stream
+	// until the synchronization ends. During the RDB transfer the offset
+	// is not known yet.
+	startAck := func() {
+		acking = true
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := r.sendOffsetAckTicker(conn, done); err != nil {
+				ackErr <- fmt.Errorf("fail send ack to master: %w", err)
+				conn.SetReadDeadline(time.Now())
+			}
+		}()
+	}
 
 	switch {
 	case strings.HasPrefix(reply, "FULLRESYNC"):
 		split := strings.Split(reply, " ")
 		if len(split) != 3 {
-			return fmt.Errorf("PSYNC FULLRESYNC response format invalid: %s", reply)
+			return false, fmt.Errorf("PSYNC FULLRESYNC response format invalid: %s", reply)
 		}
-		r.replicaId = split[1]
-		offsetInt, err := strconv.Atoi(split[2])
+		offsetInt, err := strconv.ParseInt(split[2], 10, 64)
 		if err != nil {
-			return err
+			return false, err
 		}
 
 		if partial && !r.config.ContinueIfPartialFailed {
-			return fmt.Errorf("master tells you that you need do a full synchroinzation")
+			return false, &fatalError{err: ErrFullResyncRequired}
 		}
 
-		if err := r.fullSync(offsetInt); err != nil {
-			return err
-		}
+		// The position of a previous synchronization is no longer valid.
+		r.setReplicaId("")
+		r.emit(&Event{Type: EventTypeFullResync, ReplicaId: split[1], Offset: offsetInt})
+		return true, r.fullSync(conn, split[1], offsetInt, startAck)
 	case strings.HasPrefix(reply, "CONTINUE"):
-		r.replicaId = replicaId
+		// The master sends its new replication id after a failover, and
+		// continues with the same offsets.
 		split := strings.Split(reply, " ")
-		if len(split) >= 2 {
-			r.replicaId = split[1]
+		if len(split) >= 2 && split[1] != "" {
+			replicaId = split[1]
 		}
-		r.replicaOffset.Store(int64(offset))
-		if err := r.partialSync(); err != nil {
-			return err
+		// Continue with the database selected at the offset, the stream
+		// after it is sent again.
+		r.db = r.dbAt(offset - 1)
+		r.committedDb = r.db
+		r.selects = nil
+
+		r.setReplicaId(replicaId)
+		r.replicaOffset.Store(offset - 1)
+		// What follows the committed offset up to there was control
+		// commands, or the configured position at first.
+		if r.commitOffset.Load() < offset-1 {
+			r.commitOffset.Store(offset - 1)
 		}
+
+		r.emit(&Event{Type: EventTypePartialResync, ReplicaId: replicaId, Offset: offset - 1})
+		startAck()
+		return true, r.partialSync(conn)
 	default:
-		return fmt.Errorf("unsupported PSYNC commadn response: %s", reply)
+		return false, fmt.Errorf("unsupported PSYNC commadn response: %s", reply)
 	}
+}
 
-	return nil
+// psyncOffset returns the arguments of PSYNC: the replication id and offset
+// reached by the previous connection, or the configured ones at first.
+//
+// replication.c::slaveTryPartialResynchronization
+func (r *Replica) psyncOffset() (string, int64) {
+	if r.replicaId != "" {
+		offset := r.ackOffset()
+		if offset < 0 {
+			// The RDB was not committed, it is transferred again.
+			return "?", -1
+		}
+		return r.replicaId, offset + 1
+	}
+
+	replicaId := "?"
+	offset := int64(-1)
+	if masterReplicaId := r.config.MasterReplicaId; masterReplicaId != "" {
+		replicaId = masterReplicaId
+	}
+	if masterOffset := r.config.MasterReplicaOffset; masterOffset != 0 {
+		offset = int64(masterOffset)
+	}
+	return replicaId, offset
+}
+
+func (r *Replica) emit(e *Event) {
+	if h := r.config.EventHandler; h != nil {
+		h(e)
+	}
+}
+
+// fatalError is an error that reconnecting does not solve.
+type fatalError struct {
+	err error
+}
+
+func (e *fatalError) Error() string {
+	return e.err.Error()
+}
+
+func (e *fatalError) Unwrap() error {
+	return e.err
+}
+
+// fatalWriter marks the errors of w as fatal.
+type fatalWriter struct {
+	w io.Writer
+}
+
+func (w fatalWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	if err != nil {
+		err = &fatalError{err: err}
+	}
+	return n, err
 }
 
 // replication.c::receiveSynchronousResponse
-func (r *Replica) receiveSynchronousResponse() (string, error) {
+func (r *Replica) receiveSynchronousResponse(conn *connection.Conn) (string, error) {
 	// Read the reply from the server.
-	conn := r.client.Conn()
 	for {
 		bytes, err := conn.Peek(1)
 		if err != nil {
@@ -202,26 +521,47 @@ func (r *Replica) receiveSynchronousResponse() (string, error) {
 	return conn.ReadString()
 }
 
+// Close stops the synchronization by closing the connection, cancelling the
+// context of SyncWithMaster stops it gracefully instead.
 func (r *Replica) Close() error {
-	return r.client.Close()
+	r.closeOnce.Do(func() {
+		close(r.closed)
+	})
+	return r.closeClient()
 }
 
-func (r *Replica) partialSync() error {
-	buf := make([]byte, 10*1024)
-	for {
-		n, err := r.client.Conn().Read(buf)
-		if err != nil {
-			return err
-		}
-		if _, err = r.config.AofWriter.Write(buf[:n]); err != nil {
-			return err
-		}
-		r.replicaOffset.Add(int64(n))
+func (r *Replica) closeClient() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.client == nil {
+		return nil
 	}
+	err := r.client.Close()
+	r.client = nil
+	return err
 }
 
-func (r *Replica) fullSync(offset int) error {
-	conn := r.client.Conn()
+func (r *Replica) isClosed() bool {
+	select {
+	case <-r.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+func (r *Replica) partialSync(conn *connection.Conn) error {
+	return r.syncAOF(conn)
+}
+
+// fullSync receives the RDB, then continues with the command stream with
+// ContinueAfterFullSync, calling startAck before it.
+func (r *Replica) fullSync(conn *connection.Conn, replicaId string, offset int64, startAck func()) error {
+	rdbWriter := fatalWriter{w: r.config.RdbWriter}
+	if r.config.ManualCommit {
+		// The RDB is processed once the application commits it.
+		r.commitOffset.Store(-1)
+	}
 
 	for {
 		bs, err := conn.Peek(1)
@@ -280,17 +620,17 @@ func (r *Replica) fullSync(offset int) error {
 			}
 
 			if n > 40 {
-				if _, err = r.config.RdbWriter.Write(lastBytes[:lastBytesSize]); err != nil {
+				if _, err = rdbWriter.Write(lastBytes[:lastBytesSize]); err != nil {
 					return err
 				}
 				copy(lastBytes, buf[n-40:])
 				lastBytesSize = 40
-				if _, err = r.config.RdbWriter.Write(buf[:n-40]); err != nil {
+				if _, err = rdbWriter.Write(buf[:n-40]); err != nil {
 					return err
 				}
 			} else {
 				if lastBytesSize+n > 40 {
-					if _, err = r.config.RdbWriter.Write(lastBytes[:lastBytesSize+n-40]); err != nil {
+					if _, err = rdbWriter.Write(lastBytes[:lastBytesSize+n-40]); err != nil {
 						return err
 					}
 				}
@@ -315,16 +655,26 @@ func (r *Replica) fullSync(offset int) error {
 				return err
 			}
 			unReadSize -= n
-			if _, err = r.config.RdbWriter.Write(buf[:n]); err != nil {
+			if _, err = rdbWriter.Write(buf[:n]); err != nil {
 				return err
 			}
 		}
 	}
 
-	r.replicaOffset.Store(int64(offset))
+	// The data of a previous synchronization is replaced by the RDB.
+	r.setReplicaId(replicaId)
+	r.replicaOffset.Store(offset)
+	r.lastDataOffset.Store(offset)
+	if !r.config.ManualCommit {
+		r.commitOffset.Store(offset)
+	}
+	r.db = 0
+	r.committedDb = 0
+	r.selects = nil
 
 	if r.config.ContinueAfterFullSync {
-		if err = r.syncAOF(); err != nil {
+		startAck()
+		if err = r.syncAOF(conn); err != nil {
 			return err
 		}
 	}
@@ -332,43 +682,279 @@ func (r *Replica) fullSync(offset int) error {
 	return nil
 }
 
-func (r *Replica) syncAOF() error {
-	buf := make([]byte, 1024*100)
+func (r *Replica) syncAOF(conn *connection.Conn) error {
+	return r.readCommands(conn)
+}
+
+// readCommands decodes the replication stream, copying the bytes to
+// AofWriter and passing the commands to CommandHandler or ControlHandler
+// if set.
+//
+// replication.c::replicationFeedSlaves
+func (r *Replica) readCommands(conn *connection.Conn) error {
+	var src io.Reader = conn
+	if w := r.config.AofWriter; w != nil {
+		src = io.TeeReader(src, fatalWriter{w: w})
+	}
+	cr := resp.NewCommandReader(bufio.NewReader(src))
+
+	base := r.replicaOffset.Load()
+	r.lastDataOffset.Store(base)
 	for {
-		n, err := r.client.Conn().Read(buf)
+		start := r.replicaOffset.Load()
+		args, err := cr.ReadCommand()
 		if err != nil {
 			return err
 		}
-		if _, err = r.config.AofWriter.Write(buf[:n]); err != nil {
+		end := base + cr.Offset()
+
+		switch name := strings.ToUpper(string(args[0])); {
+		case name == "SELECT":
+			if len(args) != 2 {
+				return fmt.Errorf("invalid SELECT from master")
+			}
+			db, err := strconv.Atoi(string(args[1]))
+			if err != nil {
+				return fmt.Errorf("invalid SELECT from master: %w", err)
+			}
+			r.db = db
+			if r.config.ManualCommit {
+				r.selects = append(r.selects, dbChange{offset: end, db: db})
+			}
+		case name == "PING" || name == "REPLCONF":
+			// PING is the keepalive of the master. With REPLCONF GETACK the
+			// master asks for the offset to know how far behind the replica
+			// is, e.g. for WAIT. The REPLCONF itself is not included, as
+			// Redis does.
+			if name == "REPLCONF" && len(args) >= 2 && strings.EqualFold(string(args[1]), "GETACK") {
+				if err := r.sendAck(conn); err != nil {
+					return err
+				}
+			}
+			if err := r.handleCommand(r.config.ControlHandler, args, start, end); err != nil {
+				return err
+			}
+		default:
+			r.lastDataOffset.Store(end)
+			if err := r.handleCommand(r.config.CommandHandler, args, start, end); err != nil {
+				return err
+			}
+		}
+		r.replicaOffset.Store(end)
+
+		if len(r.selects) > 0 {
+			r.pruneSelects(r.commitOffset.Load())
+		}
+		if err := r.saveCheckpoint(false); err != nil {
 			return err
 		}
-		r.replicaOffset.Add(int64(n))
 	}
 }
 
-func (r *Replica) sendOffsetAckTicker() error {
-	t := time.Tick(1 * time.Second)
-	for range t {
-		offset := r.replicaOffset.Load()
-		if offset > 0 {
-			if err := r.client.Conn().WriteCommand("REPLCONF", "ACK", strconv.FormatInt(offset, 10)); err != nil {
+// handleCommand passes the command read between start and end to h if set.
+func (r *Replica) handleCommand(h func(cmd *ReplicationCommand) error, args [][]byte, start, end int64) error {
+	if h == nil {
+		return nil
+	}
+	cmd := &ReplicationCommand{
+		Db:          r.db,
+		Args:        args,
+		StartOffset: start,
+		EndOffset:   end,
+	}
+	if err := h(cmd); err != nil {
+		return &fatalError{err: err}
+	}
+	return nil
+}
+
+// dbAt returns the database selected at offset, which is not before the
+// committed offset.
+func (r *Replica) dbAt(offset int64) int {
+	if offset >= r.replicaOffset.Load() {
+		return r.db
+	}
+	db := r.committedDb
+	for _, c := range r.selects {
+		if c.offset > offset {
+			break
+		}
+		db = c.db
+	}
+	return db
+}
+
+// pruneSelects forgets the SELECT up to offset.
+func (r *Replica) pruneSelects(offset int64) {
+	i := 0
+	for ; i < len(r.selects) && r.selects[i].offset <= offset; i++ {
+		r.committedDb = r.selects[i].db
+	}
+	r.selects = r.selects[i:]
+}
+
+// loadCheckpoint continues from the saved checkpoint, if any, at the first
+// synchronization.
+func (r *Replica) loadCheckpoint() error {
+	store := r.config.CheckpointStore
+	if store == nil || r.replicaId != "" || r.config.MasterReplicaId != "" {
+		return nil
+	}
+	cp, err := store.Load()
+	if err != nil {
+		return fmt.Errorf("load checkpoint: %w", err)
+	}
+	if cp == nil || cp.ReplicaId == "" {
+		return nil
+	}
+	r.config.MasterReplicaId = cp.ReplicaId
+	r.config.MasterReplicaOffset = int(cp.Offset + 1)
+	r.db = cp.Db
+	r.committedDb = cp.Db
+	r.checkpoint = *cp
+	return nil
+}
+
+// saveCheckpoint saves the processed position if it changed, at most once
+// per CheckpointInterval unless force is set.
+func (r *Replica) saveCheckpoint(force bool) error {
+	store := r.config.CheckpointStore
+	if store == nil || r.replicaId == "" {
+		return nil
+	}
+	interval := r.config.CheckpointInterval
+	if interval <= 0 {
+		interval = defaultCheckpointInterval
+	}
+	now := time.Now()
+	if !force && now.Sub(r.checkpointTime) < interval {
+		return nil
+	}
+
+	// Until the RDB is committed there is no position to continue from,
+	// the empty checkpoint replaces the previous one.
+	var cp Checkpoint
+	if offset := r.ackOffset(); offset >= 0 {
+		cp = Checkpoint{
+			ReplicaId: r.replicaId,
+			Offset:    offset,
+			Db:        r.dbAt(offset),
+		}
+	}
+	if cp == r.checkpoint {
+		return nil
+	}
+	if err := store.Save(&cp); err != nil {
+		return &fatalError{err: fmt.Errorf("save checkpoint: %w", err)}
+	}
+	r.checkpoint = cp
+	r.checkpointTime = now
+	return nil
+}
+
+func (r *Replica) setReplicaId(replicaId string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.replicaId = replicaId
+}
+
+// ReplicaId returns the replication id of the master, once the master
+// accepted the synchronization.
+func (r *Replica) ReplicaId() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.replicaId
+}
+
+// Offset returns the replication offset received from the master.
+func (r *Replica) Offset() int64 {
+	return r.replicaOffset.Load()
+}
+
+// ProcessedOffset returns the offset acknowledged to the master: the
+// received offset, or the committed offset with ManualCommit, -1 until the
+// RDB of a full synchronization is committed.
+func (r *Replica) ProcessedOffset() int64 {
+	return r.ackOffset()
+}
+
+// Commit acknowledges that the replication stream was processed up to
+// offset, the EndOffset of a ReplicationCommand, or the Offset of
+// EventTypeFullResync once the RDB is processed. It requires ManualCommit.
+func (r *Replica) Commit(offset int64) error {
+	if !r.config.ManualCommit {
+		return fmt.Errorf("commit requires ManualCommit")
+	}
+	if received := r.replicaOffset.Load(); offset > received && offset > r.lastDataOffset.Load() {
+		return fmt.Errorf("commit offset %d beyond the received offset %d", offset, received)
+	}
+	for {
+		current := r.commitOffset.Load()
+		if offset <= current || r.commitOffset.CompareAndSwap(current, offset) {
+			return nil
+		}
+	}
+}
+
+// ackOffset returns the offset to acknowledge to the master.
+func (r *Replica) ackOffset() int64 {
+	offset := r.replicaOffset.Load()
+	if !r.config.ManualCommit {
+		return offset
+	}
+	// Control commands after the last data command need no processing.
+	if committed := r.commitOffset.Load(); committed < r.lastDataOffset.Load() || committed > offset {
+		return committed
+	}
+	return offset
+}
+
+// replication.c::replicationSendAck
+func (r *Replica) sendAck(conn *connection.Conn) error {
+	offset := r.ackOffset()
+	if offset < 0 {
+		// Nothing is processed yet, the ACK keeps the connection alive.
+		offset = 0
+	}
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+	return conn.WriteCommand("REPLCONF", "ACK", strconv.FormatInt(offset, 10))
+}
+
+// sendOffsetAckTicker acknowledges the offset every second over conn, until
+// done is closed.
+func (r *Replica) sendOffsetAckTicker(conn *connection.Conn, done <-chan struct{}) error {
+	t := time.NewTicker(1 * time.Second)
+	defer t.Stop()
+	for {
+		select {
+		case <-done:
+			return nil
+		case <-t.C:
+			if err := r.sendAck(conn); err != nil {
 				return err
 			}
 		}
 	}
-	return nil
 }
 
-func (r *Replica) createClient() error {
-	c, err := client.NewClient(&client.Config{
+func (r *Replica) createClient(ctx context.Context) (*client.Client, error) {
+	c, err := client.NewClientContext(ctx, &client.Config{
 		Host:     r.config.MasterIP,
 		Port:     r.config.MasterPort,
 		Username: r.config.MasterUser,
 		Password: r.config.MasterPassword,
 	})
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.isClosed() {
+		c.Close()
+		return nil, &fatalError{err: ErrClosed}
 	}
 	r.client = c
-	return nil
+	return c, nil
 }
@@ -1,13 +1,22 @@
 package replica
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
 	"github.com/vczyh/redis-lib/rdb"
 	"io"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 )
 
+var errStop = errors.New("stop")
+
 func TestNewReplica_FullSync(t *testing.T) {
 	r, err := NewReplica(&Config{
 		MasterIP:       "127.0.0.1",
@@ -21,7 +30,7 @@ func TestNewReplica_FullSync(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	if err := r.SyncWithMaster(); err != nil {
+	if err := r.SyncWithMaster(context.Background()); err != nil {
 		t.Fatal(err)
 	}
 }
@@ -49,7 +58,7 @@ func TestReplica_Sync(t *testing.T) {
 		}
 	}()
 
-	if err = r.SyncWithMaster(); err != nil {
+	if err = r.SyncWithMaster(context.Background()); err != nil {
 		t.Fatal(err)
 	}
 
@@ -71,3 +80,380 @@ func parseRdb(r io.Reader) error {
 	}
 	return s.Err()
 }
+
+func TestReplica_CommandHandler(t *testing.T) {
+	stream := command("SELECT", "1") +
+		command("SET", "a", "1") +
+		command("PING") +
+		command("REPLCONF", "GETACK", "*") +
+		command("DEL", "a")
+	m := newFakeMaster(t, stream).start()
+
+	var (
+		commands []*ReplicationCommand
+		controls []*ReplicationCommand
+		aof      bytes.Buffer
+	)
+	// The RDB is discarded without RdbWriter.
+	config := &Config{
+		MasterIP:              "127.0.0.1",
+		MasterPort:            m.port(),
+		AofWriter:             &aof,
+		ContinueAfterFullSync: true,
+		CommandHandler: func(cmd *ReplicationCommand) error {
+			commands = append(commands, cmd)
+			if len(commands) == 2 {
+				return errStop
+			}
+			return nil
+		},
+		ControlHandler: func(cmd *ReplicationCommand) error {
+			controls = append(controls, cmd)
+			return nil
+		},
+	}
+	r, err := NewReplica(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.ErrorIs(t, r.SyncWithMaster(context.Background()), errStop)
+	r.Close()
+	assert.Nil(t, config.RdbWriter)
+	assert.Empty(t, config.MasterReplicaId)
+
+	if assert.Len(t, controls, 2) {
+		assert.Equal(t, [][]byte{[]byte("PING")}, controls[0].Args)
+		assert.Equal(t, 1, controls[0].Db)
+		assert.Equal(t, "REPLCONF", string(controls[1].Args[0]))
+		assert.Equal(t, controls[0].EndOffset, controls[1].StartOffset)
+	}
+
+	if !assert.Len(t, commands, 2) {
+		return
+	}
+	selectLen := int64(len(command("SELECT", "1")))
+	setLen := int64(len(command("SET", "a", "1")))
+	assert.Equal(t, &ReplicationCommand{
+		Db:          1,
+		Args:        [][]byte{[]byte("SET"), []byte("a"), []byte("1")},
+		StartOffset: 100 + selectLen,
+		EndOffset:   100 + selectLen + setLen,
+	}, commands[0])
+	assert.Equal(t, 1, commands[1].Db)
+	assert.Equal(t, [][]byte{[]byte("DEL"), []byte("a")}, commands[1].Args)
+	assert.Equal(t, int64(100+len(stream)), commands[1].EndOffset)
+	assert.Equal(t, stream, aof.String())
+}
+
+func TestReplica_ManualCommit(t *testing.T) {
+	setA := command("SET", "a", "1")
+	getAck := command("REPLCONF", "GETACK", "*")
+	setB := command("SET", "b", "2")
+	m := newFakeMaster(t, setA+getAck+setB+command("PING")+getAck).start()
+
+	var r *Replica
+	r, err := NewReplica(&Config{
+		MasterIP:              "127.0.0.1",
+		MasterPort:            m.port(),
+		RdbWriter:             io.Discard,
+		ContinueAfterFullSync: true,
+		ManualCommit:          true,
+		CommandHandler: func(cmd *ReplicationCommand) error {
+			// Only the first command is processed.
+			if string(cmd.Args[1]) == "a" {
+				return r.Commit(cmd.EndOffset)
+			}
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.SyncWithMaster(ctx)
+
+	ackA := strconv.Itoa(100 + len(setA))
+	for i := 0; i < 2; i++ {
+		select {
+		case ack := <-m.received:
+			assert.Equal(t, []string{"REPLCONF", "ACK", ackA}, ack)
+		case <-time.After(5 * time.Second):
+			t.Fatal("no ACK")
+		}
+	}
+
+	assert.Error(t, r.Commit(int64(200+len(setA+getAck+setB))))
+}
+
+func TestReplica_ManualCommitRdb(t *testing.T) {
+	m := newFakeMaster(t, command("REPLCONF", "GETACK", "*")+command("SET", "a", "1")).start()
+	store := NewFileCheckpointStore(filepath.Join(t.TempDir(), "checkpoint.json"))
+
+	// The replica stops before the RDB is committed.
+	config := &Config{
+		MasterIP:              "127.0.0.1",
+		MasterPort:            m.port(),
+		RdbWriter:             io.Discard,
+		ContinueAfterFullSync: true,
+		ManualCommit:          true,
+		CheckpointStore:       store,
+		CommandHandler: func(cmd *ReplicationCommand) error {
+			return errStop
+		},
+	}
+	r, err := NewReplica(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.ErrorIs(t, r.SyncWithMaster(context.Background()), errStop)
+	r.Close()
+	assert.Equal(t, int64(-1), r.ProcessedOffset())
+	assert.Equal(t, []string{"REPLCONF", "ACK", "0"}, <-m.received)
+
+	cp, err := store.Load()
+	assert.NoError(t, err)
+	assert.Nil(t, cp)
+
+	// After a restart, the RDB is transferred again.
+	r, err = NewReplica(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.ErrorIs(t, r.SyncWithMaster(context.Background()), errStop)
+	r.Close()
+	<-m.psyncs
+	assert.Equal(t, []string{"?", "-1"}, <-m.psyncs)
+}
+
+func TestReplica_Commit(t *testing.T) {
+	r, err := NewReplica(&Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Error(t, r.Commit(1))
+}
+
+func TestReplica_Reconnect(t *testing.T) {
+	setA := command("SET", "a", "1")
+	setB := command("SET", "b", "2")
+	setC := command("SET", "c", "3")
+	m := newFakeMaster(t, setA+setB+setC)
+	m.dropAt = len(setA) + 4
+	m.continueId = strings.Repeat("b", 40)
+	replId := m.replId
+	m.start()
+
+	var (
+		commands []*ReplicationCommand
+		events   []EventType
+	)
+	r, err := NewReplica(&Config{
+		MasterIP:              "127.0.0.1",
+		MasterPort:            m.port(),
+		RdbWriter:             io.Discard,
+		ContinueAfterFullSync: true,
+		Reconnect:             true,
+		ReconnectMinDelay:     10 * time.Millisecond,
+		CommandHandler: func(cmd *ReplicationCommand) error {
+			commands = append(commands, cmd)
+			if len(commands) == 3 {
+				return errStop
+			}
+			return nil
+		},
+		EventHandler: func(e *Event) {
+			events = append(events, e.Type)
+			if e.Type == EventTypePartialResync {
+				assert.Equal(t, m.continueId, e.ReplicaId)
+				assert.Equal(t, int64(100+len(setA)), e.Offset)
+			}
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.ErrorIs(t, r.SyncWithMaster(context.Background()), errStop)
+	r.Close()
+
+	assert.Equal(t, []string{"?", "-1"}, <-m.psyncs)
+	assert.Equal(t, []string{replId, strconv.Itoa(100 + len(setA) + 1)}, <-m.psyncs)
+	assert.Equal(t, []EventType{
+		EventTypeConnected,
+		EventTypeFullResync,
+		EventTypeDisconnected,
+		EventTypeConnected,
+		EventTypePartialResync,
+		EventTypeDisconnected,
+	}, events)
+	if !assert.Len(t, commands, 3) {
+		return
+	}
+	for i, key := range []string{"a", "b", "c"} {
+		assert.Equal(t, key, string(commands[i].Args[1]))
+	}
+	assert.Equal(t, int64(100+len(setA)), commands[1].StartOffset)
+	assert.Equal(t, int64(100+len(setA+setB+setC)), commands[2].EndOffset)
+}
+
+func TestReplica_ReconnectFullResyncAck(t *testing.T) {
+	setA := command("SET", "a", "1")
+	m := newFakeMaster(t, setA)
+	m.dropAt = len(setA)
+	m.noBacklog = true
+	m.rdbDelay = 1500 * time.Millisecond
+	m.start()
+
+	var commands int
+	r, err := NewReplica(&Config{
+		MasterIP:                "127.0.0.1",
+		MasterPort:              m.port(),
+		RdbWriter:               io.Discard,
+		ContinueIfPartialFailed: true,
+		ContinueAfterFullSync:   true,
+		Reconnect:               true,
+		ReconnectMinDelay:       10 * time.Millisecond,
+		CommandHandler: func(cmd *ReplicationCommand) error {
+			commands++
+			if commands == 2 {
+				return errStop
+			}
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.ErrorIs(t, r.SyncWithMaster(context.Background()), errStop)
+	r.Close()
+
+	assert.Len(t, m.psyncs, 2)
+	// The offset of the previous stream is not acknowledged while the new
+	// RDB is transferred, only the offset of the RDB once it is loaded.
+	assert.Empty(t, m.beforeRdb)
+	assert.Equal(t, []string{"REPLCONF", "ACK", "100"}, <-m.received)
+}
+
+func TestReplica_ReconnectFullResyncRequired(t *testing.T) {
+	m := newFakeMaster(t, "").start()
+
+	r, err := NewReplica(&Config{
+		MasterIP:            "127.0.0.1",
+		MasterPort:          m.port(),
+		MasterReplicaId:     strings.Repeat("c", 40),
+		MasterReplicaOffset: 50,
+		RdbWriter:           io.Discard,
+		Reconnect:           true,
+		ReconnectMinDelay:   10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.ErrorIs(t, r.SyncWithMaster(context.Background()), ErrFullResyncRequired)
+	r.Close()
+}
+
+func TestReplica_Checkpoint(t *testing.T) {
+	selectDb := command("SELECT", "2")
+	setA := command("SET", "a", "1")
+	setB := command("SET", "b", "2")
+	m := newFakeMaster(t, selectDb+setA+setB)
+	replId := m.replId
+	m.start()
+	store := NewFileCheckpointStore(filepath.Join(t.TempDir(), "checkpoint.json"))
+
+	// The replica stops before processing SET b.
+	r, err := NewReplica(&Config{
+		MasterIP:              "127.0.0.1",
+		MasterPort:            m.port(),
+		RdbWriter:             io.Discard,
+		ContinueAfterFullSync: true,
+		CheckpointStore:       store,
+		CommandHandler: func(cmd *ReplicationCommand) error {
+			if string(cmd.Args[1]) == "b" {
+				return errStop
+			}
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.ErrorIs(t, r.SyncWithMaster(context.Background()), errStop)
+	r.Close()
+	assert.Equal(t, replId, r.ReplicaId())
+	assert.Equal(t, int64(100+len(selectDb+setA)), r.Offset())
+
+	cp, err := store.Load()
+	assert.NoError(t, err)
+	assert.Equal(t, &Checkpoint{ReplicaId: replId, Offset: int64(100 + len(selectDb+setA)), Db: 2}, cp)
+
+	// After a restart, SET b is received again, in database 2.
+	var commands []*ReplicationCommand
+	r, err = NewReplica(&Config{
+		MasterIP:        "127.0.0.1",
+		MasterPort:      m.port(),
+		RdbWriter:       io.Discard,
+		CheckpointStore: store,
+		CommandHandler: func(cmd *ReplicationCommand) error {
+			commands = append(commands, cmd)
+			return errStop
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.ErrorIs(t, r.SyncWithMaster(context.Background()), errStop)
+	r.Close()
+
+	<-m.psyncs
+	assert.Equal(t, []string{replId, strconv.Itoa(100 + len(selectDb+setA) + 1)}, <-m.psyncs)
+	if assert.Len(t, commands, 1) {
+		assert.Equal(t, 2, commands[0].Db)
+		assert.Equal(t, "b", string(commands[0].Args[1]))
+	}
+}
+
+func TestReplica_Cancel(t *testing.T) {
+	setA := command("SET", "a", "1")
+	m := newFakeMaster(t, setA).start()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	received := make(chan struct{})
+	r, err := NewReplica(&Config{
+		MasterIP:              "127.0.0.1",
+		MasterPort:            m.port(),
+		RdbWriter:             io.Discard,
+		ContinueAfterFullSync: true,
+		Reconnect:             true,
+		CommandHandler: func(cmd *ReplicationCommand) error {
+			close(received)
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	errc := make(chan error, 1)
+	go func() {
+		errc <- r.SyncWithMaster(ctx)
+	}()
+
+	<-received
+	cancel()
+	select {
+	case err := <-errc:
+		assert.ErrorIs(t, err, context.Canceled)
+	case <-time.After(5 * time.Second):
+		t.Fatal("SyncWithMaster not stopped")
+	}
+
+	// The processed offset is acknowledged before disconnecting.
+	select {
+	case ack := <-m.received:
+		assert.Equal(t, []string{"REPLCONF", "ACK", strconv.Itoa(100 + len(setA))}, ack)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no ACK")
+	}
+}
@@ -0,0 +1,255 @@
+package replica
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/vczyh/redis-lib/rdb"
+)
+
+var errRdbAborted = errors.New("RDB transfer aborted")
+
+type StreamEventType uint8
+
+const (
+	// StreamEventTypeFullResync starts the RDB of a full synchronization.
+	// After a reconnection, the events received before are replaced by the
+	// RDB.
+	StreamEventTypeFullResync StreamEventType = iota
+	StreamEventTypeRdb
+	StreamEventTypeCommand
+)
+
+// StreamEvent is an event of Stream, only the field of its type is set.
+type StreamEvent struct {
+	Type StreamEventType
+
+	Resync  *Event
+	Rdb     *rdb.RedisRdbEvent
+	Command *ReplicationCommand
+}
+
+// Stream synchronizes with the master and returns what is received as one
+// ordered stream of events: the events of the RDB parsed by rdb.Parser, then
+// the commands of the replication stream.
+//
+//	s, _ := replica.NewStream(ctx, config)
+//	defer s.Close()
+//	for s.HasNext() {
+//		e := s.Next()
+//	}
+//	err := s.Err()
+type Stream struct {
+	replica *Replica
+	opts    []rdb.ParserOption
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	c    chan *StreamEvent
+	e    *StreamEvent
+	err  error
+	done chan struct{}
+
+	// RDB being transferred, parsed by parseRdb.
+	rdbWriter *io.PipeWriter
+	rdbDone   chan struct{}
+	rdbErr    error
+	rdbOffset int64
+}
+
+// NewStream starts synchronizing with the master. The stream sets RdbWriter
+// and CommandHandler of config, which must not be set, and calls
+// EventHandler as SyncWithMaster does. The events are returned as soon as
+// they are received, with ManualCommit the commands are acknowledged once
+// committed with Replica().Commit, and the RDB once all its events were
+// returned.
+func NewStream(ctx context.Context, config *Config, opts ...rdb.ParserOption) (*Stream, error) {
+	if config.RdbWriter != nil || config.CommandHandler != nil {
+		return nil, errors.New("stream requires RdbWriter and CommandHandler not set")
+	}
+	s := &Stream{
+		opts: opts,
+		c:    make(chan *StreamEvent),
+		done: make(chan struct{}),
+	}
+	c := *config
+	eventHandler := c.EventHandler
+	c.RdbWriter = writerFunc(s.writeRdb)
+	c.CommandHandler = s.handleCommand
+	c.EventHandler = func(e *Event) {
+		s.handleEvent(e)
+		if eventHandler != nil {
+			eventHandler(e)
+		}
+	}
+	r, err := NewReplica(&c)
+	if err != nil {
+		return nil, err
+	}
+	s.replica = r
+	s.ctx, s.cancel = context.WithCancel(ctx)
+
+	go s.run()
+	return s, nil
+}
+
+func (s *Stream) run() {
+	defer close(s.done)
+	defer close(s.c)
+
+	err := s.replica.SyncWithMaster(s.ctx)
+	if err != nil {
+		s.abortRdb(err)
+	} else {
+		// The synchronization ends after the RDB without
+		// ContinueAfterFullSync.
+		err = s.finishRdb()
+	}
+	s.err = err
+}
+
+func (s *Stream) handleEvent(e *Event) {
+	switch e.Type {
+	case EventTypeFullResync:
+		s.abortRdb(errRdbAborted)
+		if s.send(&StreamEvent{Type: StreamEventTypeFullResync, Resync: e}) != nil {
+			return
+		}
+		pr, pw := io.Pipe()
+		s.rdbWriter = pw
+		s.rdbDone = make(chan struct{})
+		s.rdbErr = nil
+		s.rdbOffset = e.Offset
+		go s.parseRdb(pr, s.rdbDone)
+	case EventTypeDisconnected:
+		// A partial RDB is replaced by the next full synchronization.
+		s.abortRdb(errRdbAborted)
+	}
+}
+
+// parseRdb passes the events of the RDB read from pr to the stream.
+func (s *Stream) parseRdb(pr *io.PipeReader, done chan struct{}) {
+	defer close(done)
+
+	p, err := rdb.NewReaderParser(pr, s.opts...)
+	if err != nil {
+		s.rdbErr = err
+		pr.CloseWithError(err)
+		return
+	}
+	st, err := p.Parse()
+	if err != nil {
+		s.rdbErr = err
+		pr.CloseWithError(err)
+		return
+	}
+	cancelled := false
+	for st.HasNext() {
+		// Once cancelled, the events are drained until the parser stops.
+		if !cancelled && s.send(&StreamEvent{Type: StreamEventTypeRdb, Rdb: st.Next()}) != nil {
+			cancelled = true
+			pr.CloseWithError(s.ctx.Err())
+		}
+	}
+	if err := st.Err(); err != nil {
+		s.rdbErr = err
+		// Fail the transfer rather than blocking it.
+		pr.CloseWithError(err)
+		return
+	}
+	// Nothing is expected after the checksum, but the transfer must not
+	// block on it.
+	io.Copy(io.Discard, pr)
+}
+
+func (s *Stream) writeRdb(p []byte) (int, error) {
+	if s.rdbWriter == nil {
+		return 0, errors.New("RDB received without FULLRESYNC")
+	}
+	return s.rdbWriter.Write(p)
+}
+
+// finishRdb waits for the RDB to be parsed, it returns the parsing error.
+// With ManualCommit the RDB is then committed.
+func (s *Stream) finishRdb() error {
+	if s.rdbWriter == nil {
+		return nil
+	}
+	s.rdbWriter.Close()
+	<-s.rdbDone
+	s.rdbWriter = nil
+	if s.rdbErr != nil {
+		return s.rdbErr
+	}
+	if s.replica.config.ManualCommit {
+		return s.replica.Commit(s.rdbOffset)
+	}
+	return nil
+}
+
+// abortRdb stops parsing an incomplete RDB.
+func (s *Stream) abortRdb(err error) {
+	if s.rdbWriter == nil {
+		return
+	}
+	s.rdbWriter.CloseWithError(err)
+	<-s.rdbDone
+	s.rdbWriter = nil
+}
+
+func (s *Stream) handleCommand(cmd *ReplicationCommand) error {
+	// The commands follow the RDB.
+	if err := s.finishRdb(); err != nil {
+		return err
+	}
+	return s.send(&StreamEvent{Type: StreamEventTypeCommand, Command: cmd})
+}
+
+func (s *Stream) send(e *StreamEvent) error {
+	select {
+	case s.c <- e:
+		return nil
+	case <-s.ctx.Done():
+		return s.ctx.Err()
+	}
+}
+
+func (s *Stream) HasNext() bool {
+	e, ok := <-s.c
+	if !ok {
+		return false
+	}
+	s.e = e
+	return true
+}
+
+func (s *Stream) Next() *StreamEvent {
+	return s.e
+}
+
+// Err returns the error ending the stream, the error of the context once it
+// is done or Close is called.
+func (s *Stream) Err() error {
+	return s.err
+}
+
+// Replica returns the replica synchronizing, e.g. to commit offsets.
+func (s *Stream) Replica() *Replica {
+	return s.replica
+}
+
+// Close stops the synchronization and waits for it to end.
+func (s *Stream) Close() error {
+	s.cancel()
+	<-s.done
+	return nil
+}
+
+// writerFunc is an io.Writer calling a function.
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) {
+	return f(p)
+}
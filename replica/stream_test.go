@@ -0,0 +1,130 @@
+package replica
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/vczyh/redis-lib/rdb"
+	"testing"
+	"time"
+)
+
+func TestStream(t *testing.T) {
+	m := newFakeMaster(t, command("SELECT", "1")+command("SET", "b", "2")).start()
+
+	s, err := NewStream(context.Background(), &Config{
+		MasterIP:              "127.0.0.1",
+		MasterPort:            m.port(),
+		ContinueAfterFullSync: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	var (
+		types   []StreamEventType
+		keys    []string
+		command *ReplicationCommand
+	)
+	for s.HasNext() {
+		e := s.Next()
+		types = append(types, e.Type)
+		switch e.Type {
+		case StreamEventTypeRdb:
+			if o, ok := e.Rdb.Event.(*rdb.StringObjectEvent); ok {
+				keys = append(keys, o.Key)
+			}
+		case StreamEventTypeCommand:
+			command = e.Command
+			s.Close()
+		}
+	}
+	assert.ErrorIs(t, s.Err(), context.Canceled)
+
+	assert.Equal(t, StreamEventTypeFullResync, types[0])
+	assert.Equal(t, StreamEventTypeCommand, types[len(types)-1])
+	for _, typ := range types[1 : len(types)-1] {
+		assert.Equal(t, StreamEventTypeRdb, typ)
+	}
+	assert.Equal(t, []string{"a"}, keys)
+	if assert.NotNil(t, command) {
+		assert.Equal(t, 1, command.Db)
+		assert.Equal(t, "b", string(command.Args[1]))
+	}
+}
+
+func TestStream_ManualCommit(t *testing.T) {
+	m := newFakeMaster(t, command("SET", "b", "2")).start()
+
+	s, err := NewStream(context.Background(), &Config{
+		MasterIP:              "127.0.0.1",
+		MasterPort:            m.port(),
+		ContinueAfterFullSync: true,
+		ManualCommit:          true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	// The RDB is committed once all its events were returned.
+	for s.HasNext() {
+		switch e := s.Next(); e.Type {
+		case StreamEventTypeRdb:
+			assert.Equal(t, int64(-1), s.Replica().ProcessedOffset())
+		case StreamEventTypeCommand:
+			assert.Equal(t, int64(100), s.Replica().ProcessedOffset())
+			s.Close()
+		}
+	}
+}
+
+func TestStream_RdbError(t *testing.T) {
+	m := newFakeMaster(t, command("SET", "b", "2"))
+	m.rdb = "REDIS0009\xfe\x00\x63\x01a"
+	m.start()
+
+	s, err := NewStream(context.Background(), &Config{
+		MasterIP:              "127.0.0.1",
+		MasterPort:            m.port(),
+		ContinueAfterFullSync: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for s.HasNext() {
+			assert.NotEqual(t, StreamEventTypeCommand, s.Next().Type)
+		}
+	}()
+	select {
+	case <-done:
+		assert.Error(t, s.Err())
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream not stopped")
+	}
+}
+
+func TestNewStream_Writers(t *testing.T) {
+	_, err := NewStream(context.Background(), &Config{
+		CommandHandler: func(cmd *ReplicationCommand) error {
+			return nil
+		},
+	})
+	assert.Error(t, err)
+
+	// NewStream does not set its handlers in config.
+	config := &Config{}
+	s, err := NewStream(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+	assert.Nil(t, config.RdbWriter)
+	assert.Nil(t, config.CommandHandler)
+	assert.Nil(t, config.EventHandler)
+}
@@ -0,0 +1,292 @@
+package resp
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"math"
+	"strconv"
+)
+
+const (
+	// Maximum length of an inline command or of a multibulk header line.
+	// server.h::PROTO_INLINE_MAX_SIZE
+	protoInlineMaxSize = 64 * 1024
+
+	DefaultMaxArgs    = math.MaxInt32
+	DefaultMaxBulkLen = maxBulkLen
+)
+
+// ProtocolError is a malformed request, with the message Redis replies with.
+type ProtocolError struct {
+	Msg string
+}
+
+func (e *ProtocolError) Error() string {
+	return "Protocol error: " + e.Msg
+}
+
+func protocolError(msg string) error {
+	return &ProtocolError{Msg: msg}
+}
+
+// CommandReader reads commands sent by clients, as a Redis server does:
+// multibulk arrays of bulk strings as well as inline commands.
+type CommandReader struct {
+	r *bufio.Reader
+
+	// Bytes consumed by the lines and payloads read so far.
+	offset int64
+
+	// Maximum number of arguments of a command.
+	MaxArgs int
+
+	// Maximum length of an argument, as proto-max-bulk-len.
+	MaxBulkLen int
+}
+
+func NewCommandReader(r *bufio.Reader) *CommandReader {
+	return &CommandReader{
+		r:          r,
+		MaxArgs:    DefaultMaxArgs,
+		MaxBulkLen: DefaultMaxBulkLen,
+	}
+}
+
+// Offset returns the number of bytes consumed from r. After ReadCommand
+// succeeds it is the offset right after the command.
+func (c *CommandReader) Offset() int64 {
+	return c.offset
+}
+
+// ReadCommand returns the arguments of the next command. Empty commands are
+// skipped as Redis does. A *ProtocolError is returned for malformed input,
+// after which the connection should be closed.
+//
+// networking.c::processInputBuffer
+func (c *CommandReader) ReadCommand() ([][]byte, error) {
+	for {
+		b, err := c.r.Peek(1)
+		if err != nil {
+			return nil, err
+		}
+
+		var args [][]byte
+		if b[0] == DataTypeArray {
+			args, err = c.readMultibulk()
+		} else {
+			args, err = c.readInline()
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(args) > 0 {
+			return args, nil
+		}
+	}
+}
+
+// networking.c::processInlineBuffer
+func (c *CommandReader) readInline() ([][]byte, error) {
+	line, err := c.readLine("too big inline request")
+	if err != nil {
+		return nil, err
+	}
+	// Redis accepts inline commands terminated by LF only.
+	line = bytes.TrimSuffix(line, []byte{'\r'})
+
+	args, err := SplitArgs(line)
+	if err != nil {
+		return nil, protocolError("unbalanced quotes in request")
+	}
+	return args, nil
+}
+
+// networking.c::processMultibulkBuffer
+func (c *CommandReader) readMultibulk() ([][]byte, error) {
+	line, err := c.readLine("too big mbulk count string")
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasSuffix(line, Separator[:1]) {
+		return nil, protocolError("invalid multibulk length")
+	}
+	n, err := strconv.ParseInt(string(line[1:len(line)-1]), 10, 64)
+	if err != nil || n > int64(c.MaxArgs) {
+		return nil, protocolError("invalid multibulk length")
+	}
+	if n <= 0 {
+		return nil, nil
+	}
+
+	args := make([][]byte, 0, allocHint(int(n)))
+	for i := int64(0); i < n; i++ {
+		line, err := c.readLine("too big bulk count string")
+		if err != nil {
+			return nil, err
+		}
+		if len(line) == 0 {
+			return nil, protocolError("expected '$', got '\n'")
+		}
+		if line[0] != DataTypeBulkString {
+			return nil, protocolError("expected '$', got '" + string(line[0]) + "'")
+		}
+		if !bytes.HasSuffix(line, Separator[:1]) {
+			return nil, protocolError("invalid bulk length")
+		}
+		size, err := strconv.ParseInt(string(line[1:len(line)-1]), 10, 64)
+		if err != nil || size < 0 || size > int64(c.MaxBulkLen) {
+			return nil, protocolError("invalid bulk length")
+		}
+		arg, err := readBulk(c.r, int(size))
+		if err == errBulkNotTerminated {
+			return nil, protocolError("bulk payload not terminated by CRLF")
+		}
+		if err != nil {
+			return nil, err
+		}
+		c.offset += size + 2
+		args = append(args, arg)
+	}
+	return args, nil
+}
+
+// readLine reads up to LF, which is removed, failing with a protocol error
+// once the line exceeds protoInlineMaxSize.
+func (c *CommandReader) readLine(tooBig string) ([]byte, error) {
+	var line []byte
+	for {
+		frag, err := c.r.ReadSlice('\n')
+		if len(line)+len(frag) > protoInlineMaxSize {
+			return nil, protocolError(tooBig)
+		}
+		line = append(line, frag...)
+		switch err {
+		case nil:
+			c.offset += int64(len(line))
+			return line[:len(line)-1], nil
+		case bufio.ErrBufferFull:
+			continue
+		default:
+			return nil, unexpectedEOF(err)
+		}
+	}
+}
+
+var errUnbalancedQuotes = errors.New("unbalanced quotes")
+
+// SplitArgs splits a line into arguments the way redis-cli and inline
+// commands do. Arguments are separated by spaces and may be quoted: double
+// quoted arguments support escapes such as \n and \x2a, single quoted ones
+// only \'.
+//
+// sds.c::sdssplitargs
+func SplitArgs(line []byte) ([][]byte, error) {
+	var args [][]byte
+	p := 0
+	for {
+		for p < len(line) && isSpace(line[p]) {
+			p++
+		}
+		if p == len(line) {
+			return args, nil
+		}
+
+		var (
+			inq, insq bool
+			done      bool
+			current   = make([]byte, 0)
+		)
+		for !done {
+			if inq {
+				switch {
+				case p == len(line):
+					return nil, errUnbalancedQuotes
+				case line[p] == '\\' && p+3 < len(line) && line[p+1] == 'x' && isHexDigit(line[p+2]) && isHexDigit(line[p+3]):
+					current = append(current, hexDigitToInt(line[p+2])*16+hexDigitToInt(line[p+3]))
+					p += 3
+				case line[p] == '\\' && p+1 < len(line):
+					p++
+					switch c := line[p]; c {
+					case 'n':
+						current = append(current, '\n')
+					case 'r':
+						current = append(current, '\r')
+					case 't':
+						current = append(current, '\t')
+					case 'b':
+						current = append(current, '\b')
+					case 'a':
+						current = append(current, '\a')
+					default:
+						current = append(current, c)
+					}
+				case line[p] == '"':
+					// Closing quote must be followed by a space or nothing.
+					if p+1 < len(line) && !isSpace(line[p+1]) {
+						return nil, errUnbalancedQuotes
+					}
+					done = true
+				default:
+					current = append(current, line[p])
+				}
+			} else if insq {
+				switch {
+				case p == len(line):
+					return nil, errUnbalancedQuotes
+				case line[p] == '\\' && p+1 < len(line) && line[p+1] == '\'':
+					p++
+					current = append(current, '\'')
+				case line[p] == '\'':
+					if p+1 < len(line) && !isSpace(line[p+1]) {
+						return nil, errUnbalancedQuotes
+					}
+					done = true
+				default:
+					current = append(current, line[p])
+				}
+			} else {
+				switch {
+				case p == len(line):
+					done = true
+				case isSpace(line[p]) || line[p] == 0:
+					done = true
+				case line[p] == '"':
+					inq = true
+				case line[p] == '\'':
+					insq = true
+				default:
+					current = append(current, line[p])
+				}
+			}
+			if p < len(line) {
+				p++
+			}
+		}
+		args = append(args, current)
+	}
+}
+
+func isSpace(c byte) bool {
+	switch c {
+	case ' ', '\n', '\r', '\t', '\v', '\f':
+		return true
+	default:
+		return false
+	}
+}
+
+func isHexDigit(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+func hexDigitToInt(c byte) byte {
+	switch {
+	case c >= '0' && c <= '9':
+		return c - '0'
+	case c >= 'a' && c <= 'f':
+		return c - 'a' + 10
+	default:
+		return c - 'A' + 10
+	}
+}
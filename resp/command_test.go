@@ -0,0 +1,104 @@
+package resp
+
+import (
+	"bufio"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"strings"
+	"testing"
+)
+
+func readCommands(t *testing.T, in string) ([][]string, error) {
+	t.Helper()
+	r := NewCommandReader(bufio.NewReader(strings.NewReader(in)))
+	var commands [][]string
+	for {
+		args, err := r.ReadCommand()
+		if err == io.EOF {
+			return commands, nil
+		}
+		if err != nil {
+			return commands, err
+		}
+		var command []string
+		for _, arg := range args {
+			command = append(command, string(arg))
+		}
+		commands = append(commands, command)
+	}
+}
+
+func TestCommandReader(t *testing.T) {
+	commands, err := readCommands(t, "*3\r\n$3\r\nSET\r\n$1\r\na\r\n$0\r\n\r\n"+
+		"PING\r\n"+
+		"\r\n"+
+		"*0\r\n"+
+		"SET a \"b c\\x41\\n\"\n"+
+		"set 'it\\'s' x\r\n")
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"SET", "a", ""},
+		{"PING"},
+		{"SET", "a", "b cA\n"},
+		{"set", "it's", "x"},
+	}, commands)
+}
+
+func TestCommandReader_ProtocolError(t *testing.T) {
+	tests := []struct {
+		in  string
+		msg string
+	}{
+		{"*x\r\n", "invalid multibulk length"},
+		{"*1\r\n:1\r\n", "expected '$', got ':'"},
+		{"*1\r\n$-1\r\n", "invalid bulk length"},
+		{"*1\r\n$1\r\nab\r\n", "bulk payload not terminated by CRLF"},
+		{"SET \"a\r\n", "unbalanced quotes in request"},
+		{"SET \"a\"b\r\n", "unbalanced quotes in request"},
+		{strings.Repeat("a", 70*1024) + "\r\n", "too big inline request"},
+	}
+	for _, tt := range tests {
+		_, err := readCommands(t, tt.in)
+		var protoErr *ProtocolError
+		if assert.True(t, errors.As(err, &protoErr), tt.in) {
+			assert.Equal(t, tt.msg, protoErr.Msg)
+		}
+	}
+
+	r := NewCommandReader(bufio.NewReader(strings.NewReader("*3\r\n$1\r\na\r\n$1\r\nb\r\n$1\r\nc\r\n")))
+	r.MaxArgs = 2
+	_, err := r.ReadCommand()
+	assert.EqualError(t, err, "Protocol error: invalid multibulk length")
+
+	r = NewCommandReader(bufio.NewReader(strings.NewReader("*1\r\n$4\r\nPING\r\n")))
+	r.MaxBulkLen = 3
+	_, err = r.ReadCommand()
+	assert.EqualError(t, err, "Protocol error: invalid bulk length")
+}
+
+func TestCommandReader_Offset(t *testing.T) {
+	in := "*1\r\n$4\r\nPING\r\n\r\nGET a\r\n"
+	r := NewCommandReader(bufio.NewReader(strings.NewReader(in)))
+
+	_, err := r.ReadCommand()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(14), r.Offset())
+
+	_, err = r.ReadCommand()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(in)), r.Offset())
+}
+
+func TestSplitArgs(t *testing.T) {
+	args, err := SplitArgs([]byte(`  a "b\tc" 'd e' f"g h"  `))
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("a"), []byte("b\tc"), []byte("d e"), []byte("fg h")}, args)
+
+	args, err = SplitArgs([]byte(""))
+	assert.NoError(t, err)
+	assert.Empty(t, args)
+
+	_, err = SplitArgs([]byte(`'abc`))
+	assert.Error(t, err)
+}
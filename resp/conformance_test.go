@@ -0,0 +1,288 @@
+package resp
+
+import (
+	"bufio"
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"strings"
+	"testing"
+)
+
+// Frames from https://redis.io/docs/reference/protocol-spec/.
+var validFrames = []string{
+	// RESP2
+	"+OK\r\n",
+	"-Error message\r\n",
+	"-ERR unknown command 'asdf'\r\n",
+	"-WRONGTYPE Operation against a key holding the wrong kind of value\r\n",
+	":0\r\n",
+	":1000\r\n",
+	":-1000\r\n",
+	":+5\r\n",
+	"$5\r\nhello\r\n",
+	"$0\r\n\r\n",
+	"$-1\r\n",
+	"*0\r\n",
+	"*-1\r\n",
+	"*2\r\n$5\r\nhello\r\n$5\r\nworld\r\n",
+	"*3\r\n:1\r\n:2\r\n:3\r\n",
+	"*5\r\n:1\r\n:2\r\n:3\r\n:4\r\n$5\r\nhello\r\n",
+	"*2\r\n*3\r\n:1\r\n:2\r\n:3\r\n*2\r\n+Hello\r\n-World\r\n",
+	"*3\r\n$5\r\nhello\r\n$-1\r\n$5\r\nworld\r\n",
+
+	// RESP3
+	"_\r\n",
+	"#t\r\n",
+	"#f\r\n",
+	",1.23\r\n",
+	",10\r\n",
+	",-1.5e10\r\n",
+	",inf\r\n",
+	",-inf\r\n",
+	",nan\r\n",
+	"(3492890328409238509324850943850943825024385\r\n",
+	"(-3492890328409238509324850943850943825024385\r\n",
+	"!21\r\nSYNTAX invalid syntax\r\n",
+	"=15\r\ntxt:Some string\r\n",
+	"%2\r\n+first\r\n:1\r\n+second\r\n:2\r\n",
+	"~5\r\n+orange\r\n+apple\r\n#t\r\n:100\r\n:999\r\n",
+	">3\r\n+message\r\n+channel\r\n+hello\r\n",
+	"|1\r\n+key-popularity\r\n%2\r\n$1\r\na\r\n,0.1923\r\n$1\r\nb\r\n,0.0012\r\n*2\r\n:2039123\r\n:9543892\r\n",
+	"*3\r\n:1\r\n:2\r\n|1\r\n+ttl\r\n:3600\r\n:3\r\n",
+	"$?\r\n;4\r\nHell\r\n;5\r\no wor\r\n;1\r\nd\r\n;0\r\n",
+	"*?\r\n:1\r\n:2\r\n:3\r\n.\r\n",
+	"%?\r\n+a\r\n:1\r\n+b\r\n:2\r\n.\r\n",
+	"~?\r\n+a\r\n.\r\n",
+}
+
+var invalidFrames = []string{
+	"\r\n",
+	"\n",
+	"+OK",
+	"+OK\n",
+	"+O\rK\r\n",
+	"x\r\n",
+	":\r\n",
+	":1.5\r\n",
+	":abc\r\n",
+	"$\r\n",
+	"$5\r\nhello",
+	"$5\r\nhelloXX",
+	"$-5\r\n",
+	"$abc\r\n",
+	"*\r\n",
+	"*2\r\n:1\r\n",
+	"*-2\r\n",
+	"_x\r\n",
+	"#\r\n",
+	"#maybe\r\n",
+	",\r\n",
+	",one\r\n",
+	"(\r\n",
+	"(12a\r\n",
+	"!-1\r\n",
+	"=3\r\nabc\r\n",
+	"=5\r\ntxtab\r\n",
+	"%1\r\n:1\r\n",
+	"%-1\r\n",
+	"|1\r\n+a\r\n+b\r\n",
+	"|?\r\n.\r\n+a\r\n",
+	"$?\r\n:1\r\n",
+	"$?\r\n;3\r\nab\r\n",
+	"*?\r\n:1\r\n",
+	"*?\r\n.x\r\n",
+	"%?\r\n+a\r\n.\r\n",
+}
+
+func TestConformance_Valid(t *testing.T) {
+	for _, frame := range validFrames {
+		r := bufio.NewReader(strings.NewReader(frame))
+		_, err := ReadValue(r)
+		if assert.NoError(t, err, "%q", frame) {
+			_, err := r.ReadByte()
+			assert.Error(t, err, "%q not fully consumed", frame)
+		}
+
+		d := NewDecoder(strings.NewReader(frame))
+		assert.NoError(t, drainDecoder(d), "%q", frame)
+	}
+}
+
+func TestConformance_Invalid(t *testing.T) {
+	for _, frame := range invalidFrames {
+		_, err := ReadValue(bufio.NewReader(strings.NewReader(frame)))
+		assert.Error(t, err, "%q", frame)
+	}
+}
+
+func TestReadData_Malformed(t *testing.T) {
+	for _, frame := range []string{"", "\r\n", "+OK\rX", "$-2\r\n", "$3\r\nab", "!x\r\n", "!-1\r\n"} {
+		r := bufio.NewReader(strings.NewReader(frame))
+		_, err := ReadString(r)
+		assert.Error(t, err, "%q", frame)
+	}
+
+	s, err := ReadString(bufio.NewReader(strings.NewReader("$-1\r\n")))
+	assert.NoError(t, err)
+	assert.Equal(t, "", s)
+}
+
+// drainDecoder reads all tokens and payloads until the end of the input.
+func drainDecoder(d *Decoder) error {
+	for {
+		tok, err := d.Next()
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if tok.Len > 0 && tok.Type != DataTypeArray && tok.Type != DataTypeMap &&
+			tok.Type != DataTypeSet && tok.Type != DataTypePush && tok.Type != DataTypeAttribute {
+			if _, err := d.ReadBulk(nil); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func FuzzReadValue(f *testing.F) {
+	for _, frame := range validFrames {
+		f.Add([]byte(frame))
+	}
+	for _, frame := range invalidFrames {
+		f.Add([]byte(frame))
+	}
+	valid := make(map[string]bool, len(validFrames))
+	for _, frame := range validFrames {
+		valid[frame] = true
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		v, err := ReadValue(bufio.NewReader(bytes.NewReader(data)))
+		if err != nil {
+			return
+		}
+
+		// Whatever is read can be written, and reads back the same.
+		var first bytes.Buffer
+		if err := WriteValue(&first, v); err != nil {
+			t.Fatalf("write %q: %s", data, err)
+		}
+		// The frames of the specification are written as they were read.
+		if valid[string(data)] && !bytes.Equal(first.Bytes(), data) {
+			t.Fatalf("re-encoded %q as %q", data, first.Bytes())
+		}
+		v2, err := ReadValue(bufio.NewReader(bytes.NewReader(first.Bytes())))
+		if err != nil {
+			t.Fatalf("read back %q: %s", first.Bytes(), err)
+		}
+		var second bytes.Buffer
+		if err := WriteValue(&second, v2); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(first.Bytes(), second.Bytes()) {
+			t.Fatalf("round trip mismatch: %q != %q", first.Bytes(), second.Bytes())
+		}
+	})
+}
+
+func FuzzDecoder(f *testing.F) {
+	for _, frame := range validFrames {
+		f.Add([]byte(frame))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		_ = drainDecoder(NewDecoder(bytes.NewReader(data)))
+	})
+}
+
+func FuzzReadData(f *testing.F) {
+	for _, frame := range validFrames {
+		f.Add([]byte(frame))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		r := bufio.NewReader(bytes.NewReader(data))
+		for {
+			if _, err := ReadString(r); err != nil {
+				return
+			}
+		}
+	})
+}
+
+func FuzzCommandReader(f *testing.F) {
+	f.Add([]byte("*2\r\n$3\r\nGET\r\n$1\r\na\r\n"))
+	f.Add([]byte("SET a \"b\\x41\" 'c'\r\n"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		r := NewCommandReader(bufio.NewReader(bytes.NewReader(data)))
+		for {
+			args, err := r.ReadCommand()
+			if err != nil {
+				return
+			}
+			if len(args) == 0 {
+				t.Fatal("empty command")
+			}
+		}
+	})
+}
+
+func FuzzWriteArray(f *testing.F) {
+	f.Add("SET", "key", "value")
+	f.Add("", "\r\n", "\x00")
+	f.Fuzz(func(t *testing.T, a, b, c string) {
+		var buf bytes.Buffer
+		if err := WriteArray(&buf, a, b, c); err != nil {
+			t.Fatal(err)
+		}
+
+		args, err := NewCommandReader(bufio.NewReader(&buf)).ReadCommand()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(args) != 3 || string(args[0]) != a || string(args[1]) != b || string(args[2]) != c {
+			t.Fatalf("got %q", args)
+		}
+	})
+}
+
+func FuzzWriteValue(f *testing.F) {
+	f.Add(byte(DataTypeBulkString), "hello", int64(0), 0.0, false)
+	f.Add(byte(DataTypeDouble), "", int64(0), 1.5, false)
+	f.Add(byte(DataTypeSimpleString), "OK", int64(0), 0.0, true)
+	f.Fuzz(func(t *testing.T, typ byte, str string, n int64, fl float64, b bool) {
+		v := Value{Type: typ, Str: str, Int: n, Float: fl, Bool: b}
+		if typ == DataTypeDouble {
+			v.Str = ""
+		}
+		var buf bytes.Buffer
+		if err := WriteValue(&buf, v); err != nil {
+			return
+		}
+		got, err := ReadValue(bufio.NewReader(&buf))
+		if err != nil {
+			t.Fatalf("read %q: %s", buf.Bytes(), err)
+		}
+		if got.Type != typ {
+			t.Fatalf("type %q != %q", got.Type, typ)
+		}
+		switch typ {
+		case DataTypeSimpleString, DataTypeSimpleError, DataTypeBulkString, DataTypeBulkError:
+			if got.Str != str {
+				t.Fatalf("%q != %q", got.Str, str)
+			}
+		case DataTypeInteger:
+			if got.Int != n {
+				t.Fatalf("%d != %d", got.Int, n)
+			}
+		case DataTypeBoolean:
+			if got.Bool != b {
+				t.Fatalf("%t != %t", got.Bool, b)
+			}
+		case DataTypeDouble:
+			if got.Float != fl && !(got.Float != got.Float && fl != fl) {
+				t.Fatalf("%v != %v", got.Float, fl)
+			}
+		}
+	})
+}
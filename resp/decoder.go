@@ -0,0 +1,218 @@
+package resp
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Token is a frame header read by Decoder.
+type Token struct {
+	// One of the DataType constants. Chunks of streamed strings have type ';'
+	// and the end of streamed aggregates has type '.'.
+	Type byte
+
+	// Payload of simple strings, simple errors, integers, doubles, booleans
+	// and big numbers. It is only valid until the next call to the decoder.
+	Line []byte
+
+	// Payload length of bulk strings, bulk errors, verbatim strings and
+	// streamed string chunks, or the number of elements of aggregates, where
+	// maps and attributes count key-value pairs. -1 for RESP2 nulls.
+	Len int64
+
+	// Whether a bulk string or an aggregate has an unknown length and is
+	// sent in streamed form.
+	Streamed bool
+}
+
+// Decoder reads RESP frames token by token, without loading bulk payloads
+// into memory: after a bulk token, the payload can be streamed with Bulk or
+// read into a reusable buffer with ReadBulk. Payloads not read are skipped by
+// the next call to Next.
+type Decoder struct {
+	r *bufio.Reader
+
+	// Reused for lines longer than the bufio.Reader buffer.
+	line []byte
+
+	bulk bulkReader
+}
+
+func NewDecoder(r io.Reader) *Decoder {
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	d := &Decoder{r: br}
+	d.bulk.d = d
+	return d
+}
+
+// Next returns the next frame header.
+func (d *Decoder) Next() (Token, error) {
+	if err := d.bulk.skip(); err != nil {
+		return Token{}, err
+	}
+
+	line, err := d.readLine()
+	if err != nil {
+		return Token{}, err
+	}
+
+	t := Token{Type: line[0]}
+	body := line[1:]
+	switch t.Type {
+	case DataTypeSimpleString, DataTypeSimpleError, DataTypeInteger, DataTypeNull,
+		DataTypeBoolean, DataTypeDouble, DataTypeBigNumbers:
+		t.Line = body
+	case streamedEnd:
+		if len(body) != 0 {
+			return Token{}, fmt.Errorf("invalid streamed aggregate end: %q", line)
+		}
+	case DataTypeBulkString, DataTypeBulkError, DataTypeVerbatimString, streamedChunk:
+		switch {
+		case t.Type == DataTypeBulkString && string(body) == "?":
+			t.Streamed = true
+			t.Len = -1
+		case t.Type == DataTypeBulkString && string(body) == "-1":
+			t.Len = -1
+		default:
+			n, err := parseLength(body, maxBulkLen)
+			if err != nil {
+				return Token{}, err
+			}
+			t.Len = int64(n)
+			// The empty chunk ends a streamed string and has no payload.
+			if t.Type != streamedChunk || n > 0 {
+				d.bulk.start(t.Len)
+			}
+		}
+	case DataTypeArray, DataTypeSet, DataTypePush, DataTypeMap, DataTypeAttribute:
+		switch {
+		case t.Type != DataTypeAttribute && string(body) == "?":
+			t.Streamed = true
+			t.Len = -1
+		case t.Type == DataTypeArray && string(body) == "-1":
+			t.Len = -1
+		default:
+			n, err := strconv.ParseInt(string(body), 10, 64)
+			if err != nil || n < 0 {
+				return Token{}, fmt.Errorf("invalid length: %q", body)
+			}
+			t.Len = n
+		}
+	default:
+		return Token{}, fmt.Errorf("unknown data type: %q", t.Type)
+	}
+	return t, nil
+}
+
+// Bulk returns a reader of the payload announced by the last token. It
+// returns io.EOF once the payload is consumed.
+func (d *Decoder) Bulk() io.Reader {
+	return &d.bulk
+}
+
+// ReadBulk reads the payload announced by the last token into dst, reusing
+// its capacity, and returns the resulting slice.
+func (d *Decoder) ReadBulk(dst []byte) ([]byte, error) {
+	n := int(d.bulk.remain)
+	if cap(dst) < n {
+		dst = make([]byte, n)
+	}
+	dst = dst[:n]
+	if _, err := io.ReadFull(&d.bulk, dst); err != nil {
+		return nil, err
+	}
+	if err := d.bulk.skip(); err != nil {
+		return nil, err
+	}
+	return dst, nil
+}
+
+func (d *Decoder) readLine() ([]byte, error) {
+	line, err := d.r.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		d.line = append(d.line[:0], line...)
+		for err == bufio.ErrBufferFull {
+			line, err = d.r.ReadSlice('\n')
+			d.line = append(d.line, line...)
+		}
+		line = d.line
+	}
+	if err != nil {
+		if err == io.EOF && len(line) > 0 {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("line not terminated by CRLF: %q", line)
+	}
+	line = line[:len(line)-2]
+	if len(line) == 0 {
+		return nil, fmt.Errorf("empty line")
+	}
+	if bytes.IndexByte(line, '\r') >= 0 {
+		return nil, fmt.Errorf("line contains CR: %q", line)
+	}
+	return line, nil
+}
+
+// bulkReader reads the payload of the current bulk token, then consumes the
+// CRLF after it.
+type bulkReader struct {
+	d *Decoder
+
+	// Whether a payload, or its CRLF, has not been consumed yet.
+	active bool
+	remain int64
+}
+
+func (b *bulkReader) start(n int64) {
+	b.active = true
+	b.remain = n
+}
+
+func (b *bulkReader) Read(p []byte) (int, error) {
+	if b.remain == 0 {
+		if err := b.skip(); err != nil {
+			return 0, err
+		}
+		return 0, io.EOF
+	}
+	if int64(len(p)) > b.remain {
+		p = p[:b.remain]
+	}
+	n, err := b.d.r.Read(p)
+	b.remain -= int64(n)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+// skip discards what is left of the payload and its CRLF.
+func (b *bulkReader) skip() error {
+	if !b.active {
+		return nil
+	}
+	if _, err := b.d.r.Discard(int(b.remain)); err != nil {
+		return unexpectedEOF(err)
+	}
+	b.remain = 0
+
+	sep, err := b.d.r.Peek(2)
+	if err != nil {
+		return unexpectedEOF(err)
+	}
+	if !bytes.Equal(sep, Separator) {
+		return fmt.Errorf("bulk payload not terminated by CRLF")
+	}
+	b.active = false
+	_, err = b.d.r.Discard(2)
+	return err
+}
@@ -0,0 +1,138 @@
+package resp
+
+import (
+	"bufio"
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestDecoder(t *testing.T) {
+	in := "*4\r\n+OK\r\n$5\r\nhello\r\n$6\r\nskip!!\r\n$-1\r\n" +
+		"$?\r\n;3\r\nabc\r\n;0\r\n" +
+		"%1\r\n:1\r\n,2.5\r\n"
+	d := NewDecoder(strings.NewReader(in))
+
+	next := func(want Token) {
+		t.Helper()
+		tok, err := d.Next()
+		if assert.NoError(t, err) {
+			assert.Equal(t, want.Type, tok.Type)
+			assert.Equal(t, want.Len, tok.Len)
+			assert.Equal(t, string(want.Line), string(tok.Line))
+			assert.Equal(t, want.Streamed, tok.Streamed)
+		}
+	}
+
+	next(Token{Type: DataTypeArray, Len: 4})
+	next(Token{Type: DataTypeSimpleString, Line: []byte("OK")})
+	next(Token{Type: DataTypeBulkString, Len: 5})
+	payload, err := io.ReadAll(d.Bulk())
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", string(payload))
+	// Skipped without being read.
+	next(Token{Type: DataTypeBulkString, Len: 6})
+	next(Token{Type: DataTypeBulkString, Len: -1})
+
+	next(Token{Type: DataTypeBulkString, Len: -1, Streamed: true})
+	next(Token{Type: ';', Len: 3})
+	buf := make([]byte, 0, 16)
+	buf, err = d.ReadBulk(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, "abc", string(buf))
+	next(Token{Type: ';', Len: 0})
+
+	next(Token{Type: DataTypeMap, Len: 1})
+	next(Token{Type: DataTypeInteger, Line: []byte("1")})
+	next(Token{Type: DataTypeDouble, Line: []byte("2.5")})
+
+	_, err = d.Next()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestDecoder_Invalid(t *testing.T) {
+	d := NewDecoder(strings.NewReader("$3\r\nabcd\r\n"))
+	_, err := d.Next()
+	assert.NoError(t, err)
+	_, err = d.Next()
+	assert.Error(t, err)
+
+	d = NewDecoder(strings.NewReader("$10\r\nabc"))
+	_, err = d.Next()
+	assert.NoError(t, err)
+	_, err = io.ReadAll(d.Bulk())
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+}
+
+func benchmarkFrames(n int, payload string) []byte {
+	var buf bytes.Buffer
+	for i := 0; i < n; i++ {
+		_ = WriteBulkString(&buf, payload)
+	}
+	return buf.Bytes()
+}
+
+func BenchmarkReadString_Small(b *testing.B) {
+	data := benchmarkFrames(1000, "value")
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r := bufio.NewReader(bytes.NewReader(data))
+		for j := 0; j < 1000; j++ {
+			if _, err := ReadString(r); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkDecoder_Small(b *testing.B) {
+	data := benchmarkFrames(1000, "value")
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	buf := make([]byte, 0, 64)
+	for i := 0; i < b.N; i++ {
+		d := NewDecoder(bytes.NewReader(data))
+		for j := 0; j < 1000; j++ {
+			if _, err := d.Next(); err != nil {
+				b.Fatal(err)
+			}
+			var err error
+			if buf, err = d.ReadBulk(buf); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkReadString_Large(b *testing.B) {
+	data := benchmarkFrames(1, strings.Repeat("x", 64<<20))
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := ReadString(bufio.NewReader(bytes.NewReader(data))); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecoder_Large(b *testing.B) {
+	data := benchmarkFrames(1, strings.Repeat("x", 64<<20))
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		d := NewDecoder(bytes.NewReader(data))
+		if _, err := d.Next(); err != nil {
+			b.Fatal(err)
+		}
+		if _, err := io.Copy(io.Discard, d.Bulk()); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package resp
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Error prefixes sent by Redis.
+const (
+	ErrorPrefixErr         = "ERR"
+	ErrorPrefixWrongType   = "WRONGTYPE"
+	ErrorPrefixMoved       = "MOVED"
+	ErrorPrefixAsk         = "ASK"
+	ErrorPrefixTryAgain    = "TRYAGAIN"
+	ErrorPrefixClusterDown = "CLUSTERDOWN"
+	ErrorPrefixCrossSlot   = "CROSSSLOT"
+	ErrorPrefixNoAuth      = "NOAUTH"
+	ErrorPrefixWrongPass   = "WRONGPASS"
+	ErrorPrefixNoPerm      = "NOPERM"
+	ErrorPrefixLoading     = "LOADING"
+	ErrorPrefixReadOnly    = "READONLY"
+	ErrorPrefixMasterDown  = "MASTERDOWN"
+	ErrorPrefixBusy        = "BUSY"
+	ErrorPrefixNoScript    = "NOSCRIPT"
+	ErrorPrefixExecAbort   = "EXECABORT"
+	ErrorPrefixOOM         = "OOM"
+)
+
+// RedisError is an error reply of the server, simple or bulk.
+type RedisError struct {
+	// First word of the error, e.g. "ERR" or "WRONGTYPE".
+	Prefix string
+
+	// Rest of the error after the prefix.
+	Message string
+
+	// Hash slot and node address of MOVED and ASK redirections.
+	Slot int
+	Addr string
+}
+
+// ParseError parses the text of an error reply, e.g.
+// "MOVED 3999 127.0.0.1:6381".
+func ParseError(s string) *RedisError {
+	e := &RedisError{}
+	e.Prefix, e.Message, _ = strings.Cut(s, " ")
+
+	if e.Prefix == ErrorPrefixMoved || e.Prefix == ErrorPrefixAsk {
+		slot, addr, ok := strings.Cut(e.Message, " ")
+		if n, err := strconv.Atoi(slot); ok && err == nil {
+			e.Slot = n
+			e.Addr = addr
+		}
+	}
+	return e
+}
+
+func (e *RedisError) Error() string {
+	if e.Message == "" {
+		return e.Prefix
+	}
+	return e.Prefix + " " + e.Message
+}
+
+// IsRedirect reports whether the error is a MOVED or ASK redirection with a
+// valid slot and address.
+func (e *RedisError) IsRedirect() bool {
+	return (e.Prefix == ErrorPrefixMoved || e.Prefix == ErrorPrefixAsk) && e.Addr != ""
+}
+
+// Err returns the error carried by an error value, nil for other types.
+func (v Value) Err() error {
+	if v.Type == DataTypeSimpleError || v.Type == DataTypeBulkError {
+		return ParseError(v.Str)
+	}
+	return nil
+}
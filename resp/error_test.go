@@ -0,0 +1,61 @@
+package resp
+
+import (
+	"bufio"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestParseError(t *testing.T) {
+	e := ParseError("MOVED 3999 127.0.0.1:6381")
+	assert.Equal(t, ErrorPrefixMoved, e.Prefix)
+	assert.Equal(t, 3999, e.Slot)
+	assert.Equal(t, "127.0.0.1:6381", e.Addr)
+	assert.True(t, e.IsRedirect())
+	assert.Equal(t, "MOVED 3999 127.0.0.1:6381", e.Error())
+
+	e = ParseError("WRONGTYPE Operation against a key holding the wrong kind of value")
+	assert.Equal(t, ErrorPrefixWrongType, e.Prefix)
+	assert.Equal(t, "Operation against a key holding the wrong kind of value", e.Message)
+	assert.False(t, e.IsRedirect())
+
+	e = ParseError("NOAUTH")
+	assert.Equal(t, ErrorPrefixNoAuth, e.Prefix)
+	assert.Equal(t, "NOAUTH", e.Error())
+}
+
+func TestReadData_Error(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader("-ASK 3999 127.0.0.1:6381\r\n!21\r\nSYNTAX invalid syntax\r\n+OK\r\n"))
+
+	_, err := ReadData(r)
+	var redisErr *RedisError
+	if assert.True(t, errors.As(err, &redisErr)) {
+		assert.Equal(t, ErrorPrefixAsk, redisErr.Prefix)
+		assert.Equal(t, 3999, redisErr.Slot)
+	}
+
+	_, err = ReadString(r)
+	if assert.True(t, errors.As(err, &redisErr)) {
+		assert.Equal(t, "SYNTAX", redisErr.Prefix)
+		assert.Equal(t, "invalid syntax", redisErr.Message)
+	}
+
+	s, err := ReadString(r)
+	assert.NoError(t, err)
+	assert.Equal(t, "OK", s)
+}
+
+func TestValue_Err(t *testing.T) {
+	v, err := readValueString("-LOADING Redis is loading the dataset in memory\r\n")
+	assert.NoError(t, err)
+	var redisErr *RedisError
+	if assert.True(t, errors.As(v.Err(), &redisErr)) {
+		assert.Equal(t, ErrorPrefixLoading, redisErr.Prefix)
+	}
+
+	v, err = readValueString("+OK\r\n")
+	assert.NoError(t, err)
+	assert.NoError(t, v.Err())
+}
@@ -0,0 +1,224 @@
+package resp
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type FormatStyle uint8
+
+const (
+	// FormatCLI formats values like redis-cli does in a terminal, one
+	// element per line.
+	FormatCLI FormatStyle = iota
+
+	// FormatCompact formats values on a single line, e.g.
+	// ["SET" "key" "value"].
+	FormatCompact
+)
+
+// Format returns a human readable representation of v.
+func Format(v Value, style FormatStyle) string {
+	switch style {
+	case FormatCompact:
+		var sb strings.Builder
+		formatCompact(&sb, v)
+		return sb.String()
+	default:
+		return formatCLI(v, "")
+	}
+}
+
+// redis-cli.c::cliFormatReplyTTY
+func formatCLI(v Value, prefix string) string {
+	switch v.Type {
+	case DataTypeSimpleError, DataTypeBulkError:
+		return "(error) " + v.Str + "\n"
+	case DataTypeSimpleString:
+		return v.Str + "\n"
+	case DataTypeInteger:
+		return "(integer) " + strconv.FormatInt(v.Int, 10) + "\n"
+	case DataTypeDouble:
+		return "(double) " + doubleText(v) + "\n"
+	case DataTypeBigNumbers:
+		return "(big number) " + v.Str + "\n"
+	case DataTypeBoolean:
+		if v.Bool {
+			return "(true)\n"
+		}
+		return "(false)\n"
+	case DataTypeNull:
+		return "(nil)\n"
+	case DataTypeVerbatimString:
+		return v.Str + "\n"
+	case DataTypeBulkString:
+		if v.Null {
+			return "(nil)\n"
+		}
+		return quote(v.Str) + "\n"
+	case DataTypeArray, DataTypeSet, DataTypeMap, DataTypePush:
+		if v.Null {
+			return "(nil)\n"
+		}
+		if len(v.Elems) == 0 {
+			switch v.Type {
+			case DataTypeMap:
+				return "(empty hash)\n"
+			case DataTypeSet:
+				return "(empty set)\n"
+			case DataTypePush:
+				return "(empty push)\n"
+			default:
+				return "(empty array)\n"
+			}
+		}
+
+		n := len(v.Elems)
+		sep := ")"
+		switch v.Type {
+		case DataTypeMap:
+			n /= 2
+			sep = "#"
+		case DataTypeSet:
+			sep = "~"
+		}
+		idxLen := len(strconv.Itoa(n))
+		// Nested elements are indented past the index.
+		nestedPrefix := prefix + strings.Repeat(" ", idxLen+2)
+
+		var sb strings.Builder
+		for i := 0; i < len(v.Elems); i++ {
+			humanIdx := i + 1
+			if v.Type == DataTypeMap {
+				humanIdx = i/2 + 1
+			}
+			// The parent already wrote the prefix of the first element.
+			if i > 0 {
+				sb.WriteString(prefix)
+			}
+			fmt.Fprintf(&sb, "%*d%s ", idxLen, humanIdx, sep)
+			elem := formatCLI(v.Elems[i], nestedPrefix)
+
+			if v.Type == DataTypeMap && i+1 < len(v.Elems) {
+				i++
+				sb.WriteString(strings.TrimSuffix(elem, "\n"))
+				sb.WriteString(" => ")
+				elem = formatCLI(v.Elems[i], nestedPrefix)
+			}
+			sb.WriteString(elem)
+		}
+		return sb.String()
+	default:
+		return fmt.Sprintf("(unknown type %q)\n", v.Type)
+	}
+}
+
+func formatCompact(sb *strings.Builder, v Value) {
+	if len(v.Attrs) > 0 {
+		sb.WriteString("|")
+		formatCompactPairs(sb, v.Attrs)
+		sb.WriteString(" ")
+	}
+
+	switch v.Type {
+	case DataTypeSimpleError, DataTypeBulkError:
+		sb.WriteString("(error) ")
+		sb.WriteString(v.Str)
+	case DataTypeSimpleString, DataTypeBigNumbers:
+		sb.WriteString(v.Str)
+	case DataTypeInteger:
+		sb.WriteString(strconv.FormatInt(v.Int, 10))
+	case DataTypeDouble:
+		sb.WriteString(doubleText(v))
+	case DataTypeBoolean:
+		sb.WriteString(strconv.FormatBool(v.Bool))
+	case DataTypeNull:
+		sb.WriteString("nil")
+	case DataTypeVerbatimString:
+		sb.WriteString(quote(v.Str))
+	case DataTypeBulkString:
+		if v.Null {
+			sb.WriteString("nil")
+			return
+		}
+		sb.WriteString(quote(v.Str))
+	case DataTypeMap:
+		formatCompactPairs(sb, v.Elems)
+	case DataTypeArray, DataTypeSet, DataTypePush:
+		if v.Null {
+			sb.WriteString("nil")
+			return
+		}
+		switch v.Type {
+		case DataTypeSet:
+			sb.WriteString("~")
+		case DataTypePush:
+			sb.WriteString(">")
+		}
+		sb.WriteString("[")
+		for i, e := range v.Elems {
+			if i > 0 {
+				sb.WriteString(" ")
+			}
+			formatCompact(sb, e)
+		}
+		sb.WriteString("]")
+	default:
+		fmt.Fprintf(sb, "(unknown type %q)", v.Type)
+	}
+}
+
+func formatCompactPairs(sb *strings.Builder, kvs []Value) {
+	sb.WriteString("{")
+	for i := 0; i+1 < len(kvs); i += 2 {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		formatCompact(sb, kvs[i])
+		sb.WriteString(": ")
+		formatCompact(sb, kvs[i+1])
+	}
+	sb.WriteString("}")
+}
+
+func doubleText(v Value) string {
+	if v.Str != "" {
+		return v.Str
+	}
+	return formatDouble(v.Float)
+}
+
+// quote returns s in double quotes with special and non printable characters
+// escaped.
+//
+// sds.c::sdscatrepr
+func quote(s string) string {
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\\', '"':
+			sb.WriteByte('\\')
+			sb.WriteByte(c)
+		case '\n':
+			sb.WriteString("\\n")
+		case '\r':
+			sb.WriteString("\\r")
+		case '\t':
+			sb.WriteString("\\t")
+		case '\a':
+			sb.WriteString("\\a")
+		case '\b':
+			sb.WriteString("\\b")
+		default:
+			if c >= 0x20 && c <= 0x7e {
+				sb.WriteByte(c)
+			} else {
+				fmt.Fprintf(&sb, "\\x%02x", c)
+			}
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
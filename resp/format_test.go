@@ -0,0 +1,48 @@
+package resp
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		in      string
+		cli     string
+		compact string
+	}{
+		{"+OK\r\n", "OK\n", "OK"},
+		{"-ERR bad\r\n", "(error) ERR bad\n", "(error) ERR bad"},
+		{":5\r\n", "(integer) 5\n", "5"},
+		{"$-1\r\n", "(nil)\n", "nil"},
+		{"$4\r\na\"\n\x01\r\n", "\"a\\\"\\n\\x01\"\n", "\"a\\\"\\n\\x01\""},
+		{",1.5\r\n", "(double) 1.5\n", "1.5"},
+		{"#t\r\n", "(true)\n", "true"},
+		{"*0\r\n", "(empty array)\n", "[]"},
+		{
+			"*3\r\n$1\r\na\r\n*2\r\n:1\r\n:2\r\n_\r\n",
+			"1) \"a\"\n2) 1) (integer) 1\n   2) (integer) 2\n3) (nil)\n",
+			"[\"a\" [1 2] nil]",
+		},
+		{
+			"%2\r\n+k1\r\n:1\r\n+k2\r\n~1\r\n+m\r\n",
+			"1# k1 => (integer) 1\n2# k2 => 1~ m\n",
+			"{k1: 1, k2: ~[m]}",
+		},
+		{
+			"*10\r\n:1\r\n:2\r\n:3\r\n:4\r\n:5\r\n:6\r\n:7\r\n:8\r\n:9\r\n*1\r\n:10\r\n",
+			" 1) (integer) 1\n 2) (integer) 2\n 3) (integer) 3\n 4) (integer) 4\n 5) (integer) 5\n" +
+				" 6) (integer) 6\n 7) (integer) 7\n 8) (integer) 8\n 9) (integer) 9\n10) 1) (integer) 10\n",
+			"[1 2 3 4 5 6 7 8 9 [10]]",
+		},
+		{"|1\r\n+ttl\r\n:10\r\n+v\r\n", "v\n", "|{ttl: 10} v"},
+	}
+	for _, tt := range tests {
+		v, err := readValueString(tt.in)
+		if !assert.NoError(t, err, tt.in) {
+			continue
+		}
+		assert.Equal(t, tt.cli, Format(v, FormatCLI), tt.in)
+		assert.Equal(t, tt.compact, Format(v, FormatCompact), tt.in)
+	}
+}
@@ -2,10 +2,7 @@ package resp
 
 import (
 	"bufio"
-	"errors"
 	"fmt"
-	"io"
-	"strconv"
 )
 
 // https://redis.io/docs/reference/protocol-spec/
@@ -24,6 +21,12 @@ const (
 	DataTypeMap            = '%' // RESP3
 	DataTypeSet            = '~' // RESP3
 	DataTypePush           = '>' // RESP3
+	DataTypeAttribute      = '|' // RESP3
+
+	// Streamed strings and aggregates of RESP3.
+	streamedLength = '?'
+	streamedChunk  = ';'
+	streamedEnd    = '.'
 )
 
 var (
@@ -42,15 +45,18 @@ func ReadString(r *bufio.Reader) (string, error) {
 	case DataTypeSimpleString:
 		return string(data[1:]), nil
 	case DataTypeBulkString:
-		size, err := getLen(data)
+		if string(data[1:]) == "-1" {
+			return "", nil
+		}
+		size, err := parseLength(data[1:], maxBulkLen)
 		if err != nil {
 			return "", err
 		}
-		v := make([]byte, size+2)
-		if _, err := io.ReadFull(r, v); err != nil {
+		v, err := readBulk(r, size)
+		if err != nil {
 			return "", err
 		}
-		return string(v[:size]), nil
+		return string(v), nil
 	}
 
 	return "", fmt.Errorf("not string type: %d", data[0])
@@ -61,8 +67,12 @@ func ReadSeparatedBytes(r *bufio.Reader) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	if _, err := r.Discard(1); err != nil {
-		return nil, err
+	b, err := r.ReadByte()
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	if b != '\n' {
+		return nil, fmt.Errorf("line not terminated by CRLF: %q", append(data, b))
 	}
 	return data[:len(data)-1], nil
 }
@@ -72,26 +82,24 @@ func ReadData(r *bufio.Reader) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
+	if len(line) == 0 {
+		return nil, fmt.Errorf("empty line")
+	}
 
 	switch line[0] {
 	case DataTypeSimpleError:
-		return nil, errors.New(string(line[1:]))
+		return nil, ParseError(string(line[1:]))
 	case DataTypeBulkError:
-		line, err := ReadSeparatedBytes(r)
-		l, err := getLen(line)
+		l, err := parseLength(line[1:], maxBulkLen)
 		if err != nil {
 			return nil, err
 		}
-		data := make([]byte, l+2)
-		if _, err := io.ReadFull(r, data); err != nil {
+		data, err := readBulk(r, l)
+		if err != nil {
 			return nil, err
 		}
-		return nil, errors.New(string(data[:l]))
+		return nil, ParseError(string(data))
 	}
 
 	return line, nil
 }
-
-func getLen(data []byte) (int, error) {
-	return strconv.Atoi(string(data[1:]))
-}
@@ -0,0 +1,351 @@
+package resp
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+)
+
+const (
+	// Maximum length of a bulk string, as proto-max-bulk-len of Redis.
+	maxBulkLen = 512 * 1024 * 1024
+
+	// Maximum depth of nested aggregates.
+	maxNesting = 1000
+)
+
+// Value is a decoded RESP2 or RESP3 frame.
+type Value struct {
+	// One of the DataType constants.
+	Type byte
+
+	// Payload of simple strings, simple errors, bulk strings, bulk errors,
+	// verbatim strings and big numbers, the textual form of doubles, and of
+	// integers not written canonically, e.g. "+5".
+	Str string
+
+	// Encoding of a verbatim string, e.g. "txt" or "mkd".
+	Format string
+
+	Int   int64
+	Float float64
+	Bool  bool
+
+	// Whether the value is a RESP2 null bulk string ($-1) or null array (*-1).
+	// The RESP3 null has Type DataTypeNull instead.
+	Null bool
+
+	// Elements of arrays, sets and pushes. Maps store keys and values
+	// alternately.
+	Elems []Value
+
+	// Attributes sent before the value, keys and values alternately.
+	Attrs []Value
+
+	// Whether a bulk string or an aggregate was sent in streamed form, with
+	// an unknown length up front.
+	Streamed bool
+
+	// Lengths of the chunks of a streamed string.
+	Chunks []int
+}
+
+// ReadValue reads one complete frame of any RESP2 or RESP3 type.
+// Errors sent by the server are returned as values of type DataTypeSimpleError
+// or DataTypeBulkError.
+func ReadValue(r *bufio.Reader) (Value, error) {
+	return readValue(r, 0)
+}
+
+func readValue(r *bufio.Reader, depth int) (Value, error) {
+	if depth > maxNesting {
+		return Value{}, fmt.Errorf("nesting exceeds %d levels", maxNesting)
+	}
+	line, err := readLine(r)
+	if err != nil {
+		return Value{}, err
+	}
+
+	v := Value{Type: line[0]}
+	body := line[1:]
+	switch v.Type {
+	case DataTypeSimpleString, DataTypeSimpleError:
+		v.Str = string(body)
+	case DataTypeInteger:
+		if v.Int, err = strconv.ParseInt(string(body), 10, 64); err != nil {
+			return Value{}, fmt.Errorf("invalid integer: %q", body)
+		}
+		if string(body) != strconv.FormatInt(v.Int, 10) {
+			v.Str = string(body)
+		}
+	case DataTypeNull:
+		if len(body) != 0 {
+			return Value{}, fmt.Errorf("invalid null: %q", body)
+		}
+	case DataTypeBoolean:
+		switch string(body) {
+		case "t":
+			v.Bool = true
+		case "f":
+			v.Bool = false
+		default:
+			return Value{}, fmt.Errorf("invalid boolean: %q", body)
+		}
+	case DataTypeDouble:
+		if v.Float, err = parseDouble(body); err != nil {
+			return Value{}, err
+		}
+		v.Str = string(body)
+	case DataTypeBigNumbers:
+		if !isBigNumber(body) {
+			return Value{}, fmt.Errorf("invalid big number: %q", body)
+		}
+		v.Str = string(body)
+	case DataTypeBulkString, DataTypeBulkError, DataTypeVerbatimString:
+		if v.Type == DataTypeBulkString && string(body) == "?" {
+			v.Streamed = true
+			v.Str, v.Chunks, err = readStreamedString(r)
+			return v, err
+		}
+		if v.Type == DataTypeBulkString && string(body) == "-1" {
+			v.Null = true
+			return v, nil
+		}
+		n, err := parseLength(body, maxBulkLen)
+		if err != nil {
+			return Value{}, err
+		}
+		data, err := readBulk(r, n)
+		if err != nil {
+			return Value{}, err
+		}
+		if v.Type == DataTypeVerbatimString {
+			if len(data) < 4 || data[3] != ':' {
+				return Value{}, fmt.Errorf("invalid verbatim string: %q", data)
+			}
+			v.Format = string(data[:3])
+			data = data[4:]
+		}
+		v.Str = string(data)
+	case DataTypeArray, DataTypeSet, DataTypePush, DataTypeMap, DataTypeAttribute:
+		if v.Type == DataTypeArray && string(body) == "-1" {
+			v.Null = true
+			return v, nil
+		}
+		if string(body) == "?" && v.Type != DataTypeAttribute {
+			v.Streamed = true
+			v.Elems, err = readStreamedAggregate(r, v.Type == DataTypeMap, depth)
+			if err != nil {
+				return Value{}, err
+			}
+			return v, nil
+		}
+		n, err := parseLength(body, math.MaxInt32)
+		if err != nil {
+			return Value{}, err
+		}
+		if v.Type == DataTypeMap || v.Type == DataTypeAttribute {
+			n *= 2
+		}
+		if v.Elems, err = readElements(r, n, depth); err != nil {
+			return Value{}, err
+		}
+		if v.Type == DataTypeAttribute {
+			// Attributes describe the value that follows them.
+			next, err := readValue(r, depth+1)
+			if err != nil {
+				return Value{}, err
+			}
+			next.Attrs = append(v.Elems, next.Attrs...)
+			return next, nil
+		}
+	default:
+		return Value{}, fmt.Errorf("unknown data type: %q", v.Type)
+	}
+
+	return v, nil
+}
+
+func readElements(r *bufio.Reader, n int, depth int) ([]Value, error) {
+	elems := make([]Value, 0, allocHint(n))
+	for i := 0; i < n; i++ {
+		e, err := readValue(r, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		elems = append(elems, e)
+	}
+	return elems, nil
+}
+
+// readStreamedAggregate reads elements until the end marker ".".
+func readStreamedAggregate(r *bufio.Reader, isMap bool, depth int) ([]Value, error) {
+	elems := make([]Value, 0)
+	for {
+		b, err := r.Peek(1)
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		if b[0] == streamedEnd {
+			line, err := readLine(r)
+			if err != nil {
+				return nil, err
+			}
+			if len(line) != 1 {
+				return nil, fmt.Errorf("invalid streamed aggregate end: %q", line)
+			}
+			if isMap && len(elems)%2 != 0 {
+				return nil, fmt.Errorf("streamed map ends without value")
+			}
+			return elems, nil
+		}
+		e, err := readValue(r, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		elems = append(elems, e)
+	}
+}
+
+// readStreamedString reads chunks ";<length>" until the empty chunk, it
+// returns their concatenation and lengths.
+func readStreamedString(r *bufio.Reader) (string, []int, error) {
+	var (
+		buf    bytes.Buffer
+		chunks []int
+	)
+	for {
+		line, err := readLine(r)
+		if err != nil {
+			return "", nil, err
+		}
+		if line[0] != streamedChunk {
+			return "", nil, fmt.Errorf("invalid streamed string chunk: %q", line)
+		}
+		n, err := parseLength(line[1:], maxBulkLen)
+		if err != nil {
+			return "", nil, err
+		}
+		if n == 0 {
+			return buf.String(), chunks, nil
+		}
+		if buf.Len()+n > maxBulkLen {
+			return "", nil, fmt.Errorf("streamed string exceeds %d bytes", maxBulkLen)
+		}
+		data, err := readBulk(r, n)
+		if err != nil {
+			return "", nil, err
+		}
+		buf.Write(data)
+		chunks = append(chunks, n)
+	}
+}
+
+// readLine reads a line terminated by CRLF, without the terminator.
+// The line is never empty.
+func readLine(r *bufio.Reader) ([]byte, error) {
+	line, err := r.ReadBytes('\n')
+	if err != nil {
+		if err == io.EOF && len(line) > 0 {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("line not terminated by CRLF: %q", line)
+	}
+	line = line[:len(line)-2]
+	if len(line) == 0 {
+		return nil, fmt.Errorf("empty line")
+	}
+	if bytes.IndexByte(line, '\r') >= 0 {
+		return nil, fmt.Errorf("line contains CR: %q", line)
+	}
+	return line, nil
+}
+
+// readBulk reads a payload of n bytes followed by CRLF.
+func readBulk(r *bufio.Reader, n int) ([]byte, error) {
+	var data []byte
+	if n <= 64*1024 {
+		data = make([]byte, n+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, unexpectedEOF(err)
+		}
+	} else {
+		// Grow with the data actually received rather than trusting the
+		// announced length.
+		var buf bytes.Buffer
+		if _, err := io.CopyN(&buf, r, int64(n)+2); err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		data = buf.Bytes()
+	}
+	if !bytes.Equal(data[n:], Separator) {
+		return nil, errBulkNotTerminated
+	}
+	return data[:n], nil
+}
+
+func parseLength(b []byte, max int) (int, error) {
+	n, err := strconv.Atoi(string(b))
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid length: %q", b)
+	}
+	if n > max {
+		return 0, fmt.Errorf("length %d exceeds %d", n, max)
+	}
+	return n, nil
+}
+
+func parseDouble(b []byte) (float64, error) {
+	switch string(b) {
+	case "inf":
+		return math.Inf(1), nil
+	case "-inf":
+		return math.Inf(-1), nil
+	case "nan", "-nan":
+		return math.NaN(), nil
+	}
+	f, err := strconv.ParseFloat(string(b), 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid double: %q", b)
+	}
+	return f, nil
+}
+
+func isBigNumber(b []byte) bool {
+	if len(b) > 0 && (b[0] == '-' || b[0] == '+') {
+		b = b[1:]
+	}
+	if len(b) == 0 {
+		return false
+	}
+	for _, c := range b {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// allocHint caps a slice capacity taken from the input.
+func allocHint(n int) int {
+	if n > 1024 {
+		return 1024
+	}
+	return n
+}
+
+var errBulkNotTerminated = errors.New("bulk payload not terminated by CRLF")
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
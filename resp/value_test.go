@@ -0,0 +1,85 @@
+package resp
+
+import (
+	"bufio"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"strings"
+	"testing"
+)
+
+func readValueString(s string) (Value, error) {
+	return ReadValue(bufio.NewReader(strings.NewReader(s)))
+}
+
+func TestReadValue(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Value
+	}{
+		{"+OK\r\n", Value{Type: DataTypeSimpleString, Str: "OK"}},
+		{"-ERR unknown\r\n", Value{Type: DataTypeSimpleError, Str: "ERR unknown"}},
+		{":-42\r\n", Value{Type: DataTypeInteger, Int: -42}},
+		{"$5\r\nhello\r\n", Value{Type: DataTypeBulkString, Str: "hello"}},
+		{"$0\r\n\r\n", Value{Type: DataTypeBulkString}},
+		{"$-1\r\n", Value{Type: DataTypeBulkString, Null: true}},
+		{"*-1\r\n", Value{Type: DataTypeArray, Null: true}},
+		{"_\r\n", Value{Type: DataTypeNull}},
+		{"#t\r\n", Value{Type: DataTypeBoolean, Bool: true}},
+		{",1.5\r\n", Value{Type: DataTypeDouble, Str: "1.5", Float: 1.5}},
+		{",-inf\r\n", Value{Type: DataTypeDouble, Str: "-inf", Float: math.Inf(-1)}},
+		{"(3492890328409238509324850943850943825024385\r\n", Value{Type: DataTypeBigNumbers, Str: "3492890328409238509324850943850943825024385"}},
+		{"!21\r\nSYNTAX invalid syntax\r\n", Value{Type: DataTypeBulkError, Str: "SYNTAX invalid syntax"}},
+		{"=15\r\ntxt:Some string\r\n", Value{Type: DataTypeVerbatimString, Format: "txt", Str: "Some string"}},
+		{"*2\r\n:1\r\n$1\r\na\r\n", Value{Type: DataTypeArray, Elems: []Value{
+			{Type: DataTypeInteger, Int: 1},
+			{Type: DataTypeBulkString, Str: "a"},
+		}}},
+		{"%1\r\n+key\r\n#f\r\n", Value{Type: DataTypeMap, Elems: []Value{
+			{Type: DataTypeSimpleString, Str: "key"},
+			{Type: DataTypeBoolean},
+		}}},
+		{"~1\r\n_\r\n", Value{Type: DataTypeSet, Elems: []Value{{Type: DataTypeNull}}}},
+		{">2\r\n+message\r\n+hi\r\n", Value{Type: DataTypePush, Elems: []Value{
+			{Type: DataTypeSimpleString, Str: "message"},
+			{Type: DataTypeSimpleString, Str: "hi"},
+		}}},
+		{"|1\r\n+ttl\r\n:3600\r\n+value\r\n", Value{
+			Type:  DataTypeSimpleString,
+			Str:   "value",
+			Attrs: []Value{{Type: DataTypeSimpleString, Str: "ttl"}, {Type: DataTypeInteger, Int: 3600}},
+		}},
+		{"$?\r\n;4\r\nHell\r\n;1\r\no\r\n;0\r\n", Value{Type: DataTypeBulkString, Str: "Hello", Streamed: true, Chunks: []int{4, 1}}},
+		{"*?\r\n:1\r\n*?\r\n.\r\n.\r\n", Value{Type: DataTypeArray, Streamed: true, Elems: []Value{
+			{Type: DataTypeInteger, Int: 1},
+			{Type: DataTypeArray, Streamed: true, Elems: []Value{}},
+		}}},
+	}
+	for _, tt := range tests {
+		v, err := readValueString(tt.in)
+		if assert.NoError(t, err, tt.in) {
+			assert.Equal(t, tt.want, v, tt.in)
+		}
+	}
+}
+
+func TestReadValue_Invalid(t *testing.T) {
+	for _, in := range []string{
+		"",
+		"\r\n",
+		"+OK\n",
+		":abc\r\n",
+		"$5\r\nhel\r\n",
+		"$3\r\nhello\r\n",
+		"$-2\r\n",
+		"#x\r\n",
+		"=3\r\ntxt\r\n",
+		"%1\r\n+key\r\n",
+		"%?\r\n+key\r\n.\r\n",
+		"?\r\n",
+		"*1\r\n",
+	} {
+		_, err := readValueString(in)
+		assert.Error(t, err, "%q", in)
+	}
+}
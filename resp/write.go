@@ -1,8 +1,11 @@
 package resp
 
 import (
+	"fmt"
 	"io"
+	"math"
 	"strconv"
+	"strings"
 )
 
 func WriteBulkString(w io.Writer, str string) error {
@@ -36,3 +39,269 @@ func WriteArray(w io.Writer, args ...string) error {
 	}
 	return nil
 }
+
+func WriteSimpleString(w io.Writer, str string) error {
+	return writeLine(w, DataTypeSimpleString, str)
+}
+
+// WriteError writes a simple error, e.g. "ERR unknown command".
+func WriteError(w io.Writer, str string) error {
+	return writeLine(w, DataTypeSimpleError, str)
+}
+
+func WriteInteger(w io.Writer, n int64) error {
+	return writeLine(w, DataTypeInteger, strconv.FormatInt(n, 10))
+}
+
+// WriteNull writes the RESP3 null.
+func WriteNull(w io.Writer) error {
+	return writeLine(w, DataTypeNull, "")
+}
+
+// WriteNullBulkString writes the RESP2 null bulk string ($-1).
+func WriteNullBulkString(w io.Writer) error {
+	return writeLine(w, DataTypeBulkString, "-1")
+}
+
+// WriteNullArray writes the RESP2 null array (*-1).
+func WriteNullArray(w io.Writer) error {
+	return writeLine(w, DataTypeArray, "-1")
+}
+
+func WriteBoolean(w io.Writer, b bool) error {
+	if b {
+		return writeLine(w, DataTypeBoolean, "t")
+	}
+	return writeLine(w, DataTypeBoolean, "f")
+}
+
+// WriteDouble writes a double, with infinities and NaN as "inf", "-inf" and
+// "nan".
+func WriteDouble(w io.Writer, f float64) error {
+	return writeLine(w, DataTypeDouble, formatDouble(f))
+}
+
+// WriteBigNumber writes a big number given in decimal, e.g.
+// "3492890328409238509324850943850943825024385".
+func WriteBigNumber(w io.Writer, n string) error {
+	if !isBigNumber([]byte(n)) {
+		return fmt.Errorf("invalid big number: %q", n)
+	}
+	return writeLine(w, DataTypeBigNumbers, n)
+}
+
+func WriteBulkError(w io.Writer, str string) error {
+	_, err := w.Write(appendBulk(nil, DataTypeBulkError, str))
+	return err
+}
+
+// WriteVerbatimString writes a verbatim string, format is a three character
+// encoding such as "txt" or "mkd".
+func WriteVerbatimString(w io.Writer, format, str string) error {
+	if len(format) != 3 {
+		return fmt.Errorf("verbatim string format must have 3 characters: %q", format)
+	}
+	_, err := w.Write(appendBulk(nil, DataTypeVerbatimString, format+":"+str))
+	return err
+}
+
+// WriteMap writes a map of bulk strings, given as alternating keys and
+// values.
+func WriteMap(w io.Writer, kvs ...string) error {
+	return writeAggregate(w, DataTypeMap, kvs)
+}
+
+// WriteSet writes a set of bulk strings.
+func WriteSet(w io.Writer, members ...string) error {
+	return writeAggregate(w, DataTypeSet, members)
+}
+
+// WritePush writes a push frame of bulk strings.
+func WritePush(w io.Writer, args ...string) error {
+	return writeAggregate(w, DataTypePush, args)
+}
+
+// WriteAttribute writes attributes of bulk strings, given as alternating keys
+// and values. The value they describe must be written next.
+func WriteAttribute(w io.Writer, kvs ...string) error {
+	return writeAggregate(w, DataTypeAttribute, kvs)
+}
+
+// WriteValue writes a value in the form ReadValue decoded it from, so that
+// frames round-trip byte for byte. Streamed strings are written as a single
+// chunk unless Chunks is set.
+func WriteValue(w io.Writer, v Value) error {
+	data, err := appendValue(nil, v)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+func appendValue(data []byte, v Value) ([]byte, error) {
+	var err error
+	if len(v.Attrs) > 0 {
+		if len(v.Attrs)%2 != 0 {
+			return nil, fmt.Errorf("attributes must have a value for every key")
+		}
+		data = appendHeader(data, DataTypeAttribute, strconv.Itoa(len(v.Attrs)/2))
+		for _, attr := range v.Attrs {
+			if data, err = appendValue(data, attr); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	switch v.Type {
+	case DataTypeSimpleString, DataTypeSimpleError:
+		if strings.ContainsAny(v.Str, "\r\n") {
+			return nil, fmt.Errorf("simple string contains CR or LF")
+		}
+		return appendHeader(data, v.Type, v.Str), nil
+	case DataTypeInteger:
+		if n, err := strconv.ParseInt(v.Str, 10, 64); err == nil && n == v.Int {
+			return appendHeader(data, v.Type, v.Str), nil
+		}
+		return appendHeader(data, v.Type, strconv.FormatInt(v.Int, 10)), nil
+	case DataTypeNull:
+		return appendHeader(data, v.Type, ""), nil
+	case DataTypeBoolean:
+		if v.Bool {
+			return appendHeader(data, v.Type, "t"), nil
+		}
+		return appendHeader(data, v.Type, "f"), nil
+	case DataTypeDouble:
+		if v.Str != "" {
+			return appendHeader(data, v.Type, v.Str), nil
+		}
+		return appendHeader(data, v.Type, formatDouble(v.Float)), nil
+	case DataTypeBigNumbers:
+		if !isBigNumber([]byte(v.Str)) {
+			return nil, fmt.Errorf("invalid big number: %q", v.Str)
+		}
+		return appendHeader(data, v.Type, v.Str), nil
+	case DataTypeBulkString:
+		switch {
+		case v.Null:
+			return appendHeader(data, v.Type, "-1"), nil
+		case v.Streamed:
+			data = appendHeader(data, v.Type, string(streamedLength))
+			for _, chunk := range streamedChunks(v) {
+				data = appendBulk(data, streamedChunk, chunk)
+			}
+			return appendHeader(data, streamedChunk, "0"), nil
+		default:
+			return appendBulk(data, v.Type, v.Str), nil
+		}
+	case DataTypeBulkError:
+		return appendBulk(data, v.Type, v.Str), nil
+	case DataTypeVerbatimString:
+		if len(v.Format) != 3 {
+			return nil, fmt.Errorf("verbatim string format must have 3 characters: %q", v.Format)
+		}
+		return appendBulk(data, v.Type, v.Format+":"+v.Str), nil
+	case DataTypeArray, DataTypeSet, DataTypePush, DataTypeMap:
+		if v.Null {
+			if v.Type != DataTypeArray {
+				return nil, fmt.Errorf("null is only valid for arrays")
+			}
+			return appendHeader(data, v.Type, "-1"), nil
+		}
+		n := len(v.Elems)
+		if v.Type == DataTypeMap {
+			if n%2 != 0 {
+				return nil, fmt.Errorf("map must have a value for every key")
+			}
+			n /= 2
+		}
+		if v.Streamed {
+			data = appendHeader(data, v.Type, string(streamedLength))
+		} else {
+			data = appendHeader(data, v.Type, strconv.Itoa(n))
+		}
+		for _, e := range v.Elems {
+			if data, err = appendValue(data, e); err != nil {
+				return nil, err
+			}
+		}
+		if v.Streamed {
+			data = appendHeader(data, streamedEnd, "")
+		}
+		return data, nil
+	default:
+		return nil, fmt.Errorf("unknown data type: %q", v.Type)
+	}
+}
+
+func writeLine(w io.Writer, t byte, str string) error {
+	if strings.ContainsAny(str, "\r\n") {
+		return fmt.Errorf("simple string contains CR or LF")
+	}
+	_, err := w.Write(appendHeader(nil, t, str))
+	return err
+}
+
+func writeAggregate(w io.Writer, t byte, elems []string) error {
+	n := len(elems)
+	if t == DataTypeMap || t == DataTypeAttribute {
+		if n%2 != 0 {
+			return fmt.Errorf("odd number of keys and values: %d", n)
+		}
+		n /= 2
+	}
+	data := appendHeader(nil, t, strconv.Itoa(n))
+	for _, e := range elems {
+		data = appendBulk(data, DataTypeBulkString, e)
+	}
+	_, err := w.Write(data)
+	return err
+}
+
+func appendHeader(data []byte, t byte, str string) []byte {
+	data = append(data, t)
+	data = append(data, str...)
+	return append(data, Separator...)
+}
+
+func appendBulk(data []byte, t byte, str string) []byte {
+	data = appendHeader(data, t, strconv.Itoa(len(str)))
+	data = append(data, str...)
+	return append(data, Separator...)
+}
+
+func formatDouble(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	case math.IsNaN(f):
+		return "nan"
+	default:
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+}
+
+// streamedChunks splits a streamed string as Chunks, or in a single chunk if
+// Chunks does not match the string.
+func streamedChunks(v Value) []string {
+	var (
+		chunks []string
+		rest   = v.Str
+	)
+	for _, n := range v.Chunks {
+		if n <= 0 || n > len(rest) {
+			break
+		}
+		chunks = append(chunks, rest[:n])
+		rest = rest[n:]
+	}
+	if len(chunks) != len(v.Chunks) || rest != "" {
+		if v.Str == "" {
+			return nil
+		}
+		return []string{v.Str}
+	}
+	return chunks
+}
@@ -0,0 +1,93 @@
+package resp
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"testing"
+)
+
+func TestWriters(t *testing.T) {
+	tests := []struct {
+		write func(w *bytes.Buffer) error
+		want  string
+	}{
+		{func(w *bytes.Buffer) error { return WriteSimpleString(w, "OK") }, "+OK\r\n"},
+		{func(w *bytes.Buffer) error { return WriteError(w, "ERR bad") }, "-ERR bad\r\n"},
+		{func(w *bytes.Buffer) error { return WriteInteger(w, -7) }, ":-7\r\n"},
+		{func(w *bytes.Buffer) error { return WriteNull(w) }, "_\r\n"},
+		{func(w *bytes.Buffer) error { return WriteNullBulkString(w) }, "$-1\r\n"},
+		{func(w *bytes.Buffer) error { return WriteNullArray(w) }, "*-1\r\n"},
+		{func(w *bytes.Buffer) error { return WriteBoolean(w, false) }, "#f\r\n"},
+		{func(w *bytes.Buffer) error { return WriteDouble(w, 3.25) }, ",3.25\r\n"},
+		{func(w *bytes.Buffer) error { return WriteDouble(w, math.Inf(1)) }, ",inf\r\n"},
+		{func(w *bytes.Buffer) error { return WriteDouble(w, math.NaN()) }, ",nan\r\n"},
+		{func(w *bytes.Buffer) error { return WriteBigNumber(w, "-12345678901234567890") }, "(-12345678901234567890\r\n"},
+		{func(w *bytes.Buffer) error { return WriteBulkError(w, "SYNTAX bad") }, "!10\r\nSYNTAX bad\r\n"},
+		{func(w *bytes.Buffer) error { return WriteVerbatimString(w, "txt", "hi") }, "=6\r\ntxt:hi\r\n"},
+		{func(w *bytes.Buffer) error { return WriteMap(w, "k", "v") }, "%1\r\n$1\r\nk\r\n$1\r\nv\r\n"},
+		{func(w *bytes.Buffer) error { return WriteSet(w, "a") }, "~1\r\n$1\r\na\r\n"},
+		{func(w *bytes.Buffer) error { return WritePush(w, "message", "hi") }, ">2\r\n$7\r\nmessage\r\n$2\r\nhi\r\n"},
+		{func(w *bytes.Buffer) error { return WriteAttribute(w, "ttl", "10") }, "|1\r\n$3\r\nttl\r\n$2\r\n10\r\n"},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if assert.NoError(t, tt.write(&buf)) {
+			assert.Equal(t, tt.want, buf.String())
+		}
+	}
+
+	var buf bytes.Buffer
+	assert.Error(t, WriteSimpleString(&buf, "a\r\nb"))
+	assert.Error(t, WriteMap(&buf, "k"))
+	assert.Error(t, WriteVerbatimString(&buf, "text", "hi"))
+	assert.Error(t, WriteBigNumber(&buf, "1.5"))
+}
+
+func TestWriteValue_RoundTrip(t *testing.T) {
+	for _, in := range []string{
+		"+OK\r\n",
+		"-ERR unknown\r\n",
+		":-42\r\n",
+		"$5\r\nhello\r\n",
+		"$-1\r\n",
+		"*-1\r\n",
+		"_\r\n",
+		"#t\r\n",
+		",1.50\r\n",
+		",-inf\r\n",
+		"(3492890328409238509324850943850943825024385\r\n",
+		"!21\r\nSYNTAX invalid syntax\r\n",
+		"=15\r\ntxt:Some string\r\n",
+		"*2\r\n:1\r\n$1\r\na\r\n",
+		"%1\r\n+key\r\n#f\r\n",
+		"~1\r\n_\r\n",
+		">2\r\n+message\r\n+hi\r\n",
+		"|1\r\n+ttl\r\n:3600\r\n+value\r\n",
+		"*1\r\n|1\r\n+a\r\n+b\r\n:1\r\n",
+		":+5\r\n",
+		"$?\r\n;5\r\nHello\r\n;0\r\n",
+		"$?\r\n;4\r\nHell\r\n;1\r\no\r\n;0\r\n",
+		"$?\r\n;0\r\n",
+		"%?\r\n+a\r\n:1\r\n.\r\n",
+	} {
+		v, err := readValueString(in)
+		if !assert.NoError(t, err, in) {
+			continue
+		}
+		var buf bytes.Buffer
+		if assert.NoError(t, WriteValue(&buf, v), in) {
+			assert.Equal(t, in, buf.String())
+		}
+	}
+}
+
+func TestWriteValue_StreamedChunks(t *testing.T) {
+	for _, chunks := range [][]int{nil, {5}, {1, 0, 2}} {
+		var buf bytes.Buffer
+		v := Value{Type: DataTypeBulkString, Str: "abc", Streamed: true, Chunks: chunks}
+		if assert.NoError(t, WriteValue(&buf, v)) {
+			assert.Equal(t, "$?\r\n;3\r\nabc\r\n;0\r\n", buf.String(), chunks)
+		}
+	}
+}